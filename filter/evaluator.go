@@ -0,0 +1,316 @@
+package filter
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"githib.com/JeffreyRichter/filter/collections"
+	"githib.com/JeffreyRichter/filter/parser"
+)
+
+// EvaluateOption customizes how Evaluate applies a filter.
+type EvaluateOption func(e *evaluator)
+
+// WithNow sets the function Evaluate calls (once) to get the time returned by now(); the default is time.Now.
+func WithNow(now func() time.Time) EvaluateOption {
+	return func(e *evaluator) { e.now = now }
+}
+
+// WithThreeValuedLogic makes Evaluate use the three-valued logic of SQL and OData, so a filter produces
+// the same result in memory as it does in a database. A comparison, in, function call, or lambda involving
+// null (a property that doesn't exist) is unknown rather than false, except that eq null and ne null test
+// whether a value is null. not unknown is unknown; false and unknown is false; true or unknown is true;
+// any other and/or involving unknown is unknown. any is unknown if no element's predicate is true and
+// some element's is unknown; all is unknown if no element's predicate is false and some element's is unknown.
+// If the whole filter is unknown, Evaluate returns false.
+func WithThreeValuedLogic() EvaluateOption {
+	return func(e *evaluator) { e.threeValued = true }
+}
+
+// MissingPolicy determines what Evaluate does with a property that doesn't exist.
+type MissingPolicy int
+
+const (
+	// MissingIsNull treats a property that doesn't exist as if its value were null: eq null is true,
+	// any other comparison is false, and a function returns null (a predicate returns false).
+	MissingIsNull MissingPolicy = iota
+
+	// MissingIsFalse makes every comparison, in, predicate function, and lambda involving a property
+	// that doesn't exist false, including eq null and ne null; a non-predicate function or arithmetic
+	// operation involving the property passes the missing value on to the enclosing predicate.
+	MissingIsFalse
+
+	// MissingIsError makes Evaluate return an error naming the full path of a property that doesn't exist.
+	// Only the operands Evaluate reaches are checked: and and or stop at the first operand that decides
+	// the result, so int eq 23 or nope eq 1 is true without an error when int is 23.
+	MissingIsError
+)
+
+// WithMissingProperties sets how Evaluate treats a property that doesn't exist; the default is MissingIsNull.
+// A property exists if each name in its path is found in an object and each array index is in range;
+// a property whose value is null exists.
+func WithMissingProperties(policy MissingPolicy) EvaluateOption {
+	return func(e *evaluator) { e.missing = policy }
+}
+
+// missing is the value of a property that doesn't exist under the MissingIsFalse policy.
+type missing struct{}
+
+// isMissing returns true if any of values is the value of a property that doesn't exist (under MissingIsFalse).
+func isMissing(values ...any) bool {
+	for _, v := range values {
+		if _, ok := v.(missing); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluator applies a filter's expression tree to a map.
+type evaluator struct {
+	m           map[string]any              // The map the filter is applied to
+	now         func() time.Time            // Returns the time for the environment
+	threeValued bool                        // true if a boolean expression may be unknown (nil)
+	missing     MissingPolicy               // How to treat a property that doesn't exist
+	env         parser.Environment          // Passed to functions
+	variables   collections.Stack[variable] // The range variables of the lambdas being evaluated
+}
+
+// variable is a lambda's range variable and the array element it currently refers to.
+type variable struct {
+	name       string
+	value      any
+	collection *parser.Property // The lambda's collection; used to describe the element's path
+	index      int              // The element's index in the collection
+}
+
+// evaluateBool evaluates node; node must produce a boolean. Unknown (see WithThreeValuedLogic) is false.
+func (e *evaluator) evaluateBool(node parser.Node) (bool, error) {
+	b, _, err := e.evaluateTruth(node)
+	return b, err
+}
+
+// evaluateTruth evaluates node; node must produce a boolean or, with three-valued logic, nil.
+// known is false if the result is unknown (nil), in which case b is false.
+func (e *evaluator) evaluateTruth(node parser.Node) (b, known bool, err error) {
+	v, err := e.evaluate(node)
+	if err != nil {
+		return false, false, err
+	}
+	if v == nil && e.threeValued {
+		return false, false, nil
+	}
+	b, ok := v.(bool)
+	if !ok && !isMissing(v) {
+		return false, false, fmt.Errorf("Expression doesn't produce a boolean: %s", node)
+	}
+	return b, true, nil
+}
+
+// isUnknown returns true if, with three-valued logic, an operation on values is unknown because one of them is null.
+func (e *evaluator) isUnknown(values ...any) bool {
+	for _, v := range values {
+		if v == nil && e.threeValued {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate returns the value node produces when applied to the evaluator's map.
+func (e *evaluator) evaluate(node parser.Node) (any, error) {
+	switch n := node.(type) {
+	case *parser.Logical:
+		decisive := n.Op == parser.LogicalOr // true or ... / false and ...: no need to evaluate the right
+		l, lKnown, err := e.evaluateTruth(n.Left)
+		if err != nil {
+			return false, err
+		}
+		if lKnown && l == decisive {
+			return l, nil
+		}
+		r, rKnown, err := e.evaluateTruth(n.Right)
+		switch {
+		case err != nil:
+			return false, err
+		case rKnown && r == decisive:
+			return r, nil
+		case !lKnown || !rKnown:
+			return nil, nil // unknown
+		}
+		return r, nil
+
+	case *parser.Not:
+		b, known, err := e.evaluateTruth(n.Operand)
+		if err != nil || !known {
+			return nil, err
+		}
+		return !b, nil
+
+	case *parser.Comparison:
+		left, err := e.evaluate(n.Left)
+		if err != nil {
+			return false, err
+		}
+		right, err := e.evaluate(n.Right)
+		if err != nil {
+			return false, err
+		}
+		if isMissing(left, right) {
+			return false, nil
+		}
+		if l, ok := n.Right.(*parser.Literal); (!ok || l.Value != nil) && e.isUnknown(left, right) {
+			return nil, nil // Only eq null and ne null test for null
+		}
+		return n.Evaluate(left, right)
+
+	case *parser.In:
+		left, err := e.evaluate(n.Left)
+		if err != nil || e.isUnknown(left) {
+			return nil, err
+		}
+		if isMissing(left) {
+			return false, nil
+		}
+		return n.Evaluate(left)
+
+	case *parser.Arithmetic:
+		left, err := e.evaluate(n.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := e.evaluate(n.Right)
+		if err != nil {
+			return nil, err
+		}
+		if isMissing(left, right) {
+			return missing{}, nil
+		}
+		return n.Evaluate(left, right)
+
+	case *parser.Call:
+		if n.TestsExistence() { // Test the property without evaluating it so the missing-property policy doesn't apply
+			_, exists := e.getPropValue(n.Args[0].(*parser.Property))
+			return n.Evaluate(e.env, []any{exists})
+		}
+		args := make([]any, len(n.Args))
+		for i, a := range n.Args {
+			v, err := e.evaluate(a)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		if e.isUnknown(args...) {
+			return nil, nil
+		}
+		if isMissing(args...) {
+			if n.Kind() == parser.KindBoolean {
+				return false, nil
+			}
+			return missing{}, nil
+		}
+		return n.Evaluate(e.env, args)
+
+	case *parser.Lambda:
+		return e.evaluateLambda(n)
+
+	case *parser.Property:
+		return e.evaluateProperty(n)
+
+	case *parser.Literal:
+		return n.Value, nil
+	}
+	panic(fmt.Sprintf("Unrecognized node type: %T", node)) // The parser produced a node we don't know about
+}
+
+// evaluateProperty returns the value of property p, applying the missing-property policy if p doesn't exist.
+func (e *evaluator) evaluateProperty(p *parser.Property) (any, error) {
+	v, ok := e.getPropValue(p)
+	if ok {
+		return v, nil
+	}
+	switch e.missing {
+	case MissingIsError:
+		return nil, fmt.Errorf("Property not found: %s", e.fullPath(p, len(e.variables)))
+	case MissingIsFalse:
+		return missing{}, nil
+	}
+	return nil, nil
+}
+
+// getPropValue returns the value of property p; ok is false if it doesn't exist.
+func (e *evaluator) getPropValue(p *parser.Property) (value any, ok bool) {
+	if p.Variable == "" {
+		return getPropValue(p.Path, e.m)
+	}
+	i := e.lookup(p.Variable, len(e.variables))
+	return getPropValue(p.Path, e.variables[i].value)
+}
+
+// lookup returns the index of the innermost range variable named name among the first n variables.
+func (e *evaluator) lookup(name string, n int) int {
+	for i := n - 1; i >= 0; i-- { // Search from the innermost lambda outward
+		if e.variables[i].name == name {
+			return i
+		}
+	}
+	panic(fmt.Sprintf("Range variable not in scope: %s", name)) // The parser only produces variables that are in scope
+}
+
+// fullPath returns p with any range variable replaced by the path of the array element it refers to
+// (ex: items[2].price); p's variable is among the first n variables.
+func (e *evaluator) fullPath(p *parser.Property, n int) *parser.Property {
+	if p.Variable == "" {
+		return p
+	}
+	i := e.lookup(p.Variable, n)
+	element := e.fullPath(e.variables[i].collection, i) // The collection's variables are outside the lambda
+	path := append(append(element.Path[:len(element.Path):len(element.Path)], strconv.Itoa(e.variables[i].index)), p.Path...)
+	return &parser.Property{Path: path, Position: p.Position}
+}
+
+// evaluateLambda applies n's predicate to the elements of n's collection. any returns true if
+// the predicate is true for any element; all returns true if it is true for every element.
+// With three-valued logic, the result may be unknown (nil).
+func (e *evaluator) evaluateLambda(n *parser.Lambda) (any, error) {
+	collection, err := e.evaluateProperty(n.Collection)
+	if err != nil {
+		return false, err
+	}
+	if isMissing(collection) {
+		return false, nil
+	}
+	if collection == nil {
+		if e.threeValued {
+			return nil, nil
+		}
+		return false, nil // Property doesn't exist
+	}
+	array := reflect.ValueOf(collection)
+	if k := array.Kind(); k != reflect.Slice && k != reflect.Array {
+		return false, fmt.Errorf("Type mismatch: %s='%v' must be an array", n.Collection, collection)
+	}
+	if n.Predicate == nil { // any()
+		return array.Len() > 0, nil
+	}
+	want, unknown := n.Op == parser.LambdaAny, false // any stops at the 1st true; all stops at the 1st false
+	for i := 0; i < array.Len(); i++ {
+		e.variables.Push(variable{name: n.Variable, value: array.Index(i).Interface(), collection: n.Collection, index: i})
+		b, known, err := e.evaluateTruth(n.Predicate)
+		e.variables.Pop()
+		if err != nil {
+			return false, err
+		}
+		if known && b == want {
+			return want, nil
+		}
+		unknown = unknown || !known
+	}
+	if unknown {
+		return nil, nil
+	}
+	return !want, nil
+}
@@ -1,157 +1,235 @@
 package filter
 
 import (
-	"strings"
+	"reflect"
+	"time"
 
-	"githib.com/JeffreyRichter/filter/collections"
 	"githib.com/JeffreyRichter/filter/parser"
 )
 
-//var showNode = func(node parser.Node) { fmt.Printf("%#v\n", node) }
+// var showNode = func(node parser.Node) { fmt.Printf("%s\n", node) }
 var showNode = func(node parser.Node) {}
 
 // Filter is a parsed filter created by New.
-type Filter []parser.Node
+type Filter struct {
+	root parser.Node // The root of the filter's expression tree
+}
 
 // New parses a filter string and returns a Filter.
 // Here's an example of a filter string:
-//    (name eq 'Jeff' and age gt 30) or (student eq true and semester.gpa gt 3.5) and graduated gt time'2020-01-01'
+//
+//	(name eq 'Jeff' and age gt 30) or (student eq true and semester.gpa gt 3.5) and graduated gt time'2020-01-01'
+//
+// Any Unicode whitespace, including line breaks, separates tokens. Comments are ignored: // or -- starts
+// a comment that ends at the end of the line and /* starts a comment that ends at */
 // A logical operation is one of: and, or; or has lower precedence: A and B or C and D means (A and B) or (C and D)
+// The not operator negates the comparison, function call, or parenthesized expression that follows it;
+// it has higher precedence than and: not A and B means (not A) and B
 // A comparison operation is one of: eq, ne, gt, ge, lt, le.
-// A JSON property name is a string literal; use a period to step into child objects (ex: gpa is a child of semester).
+// Either side may be any expression: used le quota, 5 lt count, 'admin' eq role
+// The in operation tests membership in a parenthesized list of literals of the same type: status in ('open', 'closed')
+// An arithmetic operation is one of: add, sub, mul, div, divby, mod; mul, div, divby, and mod have higher
+// precedence than add and sub. div on integers truncates; divby produces a float (or a decimal, see below).
+// Integer overflow and division by zero are evaluation errors. Numbers of different types (see Evaluate) are
+// compared exactly, without first rounding an integer to a float: 9007199254740993 gt 9007199254740992e0 is true.
+// Decimals and integers are added, subtracted, multiplied, and compared exactly: 0.1 add 0.2 eq 0.3 is true;
+// a quotient that isn't a decimal (1.0 div 3) is rounded to 34 significant digits. A decimal is converted to
+// the nearest float when it meets a float: float eq 0.1 is true if float is the float64 nearest to 0.1.
+// add and sub also apply to times and durations: time add duration and time sub duration produce a time;
+// time sub time produces a duration; duration add duration and duration sub duration produce a duration.
+// A JSON property name is made of letters, digits, underscores, and dashes and starts with a letter or underscore;
+// use a period or slash to step into child objects (ex: gpa is a child of semester; see WithPathSeparators).
+// An integer name or an integer in brackets steps into an array element: items/0/name, items[0].name, items[-1]
+// (a negative index counts back from the end of the array); an index out of range is a property that doesn't
+// exist. An integer name is a property name if the value is an object rather than an array. Any other name is enclosed
+// in double quotes, doubling any double quote within it: "odd.key".child, "k8s.io/name", "say ""hi""".
+// A property may also be a JSON Pointer (RFC 6901), where ~1 represents a slash and ~0 a tilde: /metadata/k8s.io~1name
+// A JSON Pointer always starts at the filtered object (even within a lambda) and must not immediately follow a name.
+// The any and all lambda operators test a predicate against an array's elements:
+//
+//	tags/any(t: t eq 'urgent')                  true if any element is 'urgent'
+//	items/all(i: i/price lt 100)                true if every element's price is less than 100
+//	orders/any(o: o/items/any(i: i/qty gt 5))   lambdas may be nested
+//	tags/any()                                  true if the array isn't empty
+//
 // A literal value (after a comparison operator) can be:
-//   boolean: true | false
-//   integer: (+|-) <digits> -- no decimal point
-//   float:   (+|-) <digits> . <digits>
-//   string:  '<alphanumeric characters>'
-//   time:    time'<rfc3339 time>'
-//   null     (represents the precense (ne)/absense(eq) of a property)
-func New(filter string) (Filter, error) {
-	parseNodes, err := inFixToPostFix(parser.GetNodes(filter))
+//
+//	boolean: true | false
+//	integer: (+|-) <digits> (l|L) -- no decimal point; an integer outside int64's range is a *big.Int
+//	decimal: (+|-) <digits> . <digits> (m|M), .5, or an integer with an m or M suffix -- an exact parser.Decimal;
+//	         it also compares with a string in decimal form: price eq 19.99 is true if price is '19.99',
+//	         as does price in (19.99, 20) (a list with a decimal)
+//	double:  a number with an exponent (1e6, 2.5E-3) or a d, D, f, or F suffix, INF, -INF, or NaN
+//	string:  '<any characters>' -- a quote within a string is doubled: 'O''Brien'
+//	time:    time'<rfc3339 time>'
+//	date:    yyyy-mm-dd
+//	time of day: hh:mm[:ss[.fraction]] (ex: 13:45:00; compare with time(t) or a parser.TimeOfDay)
+//	duration: duration'<ISO 8601 duration>' with days, hours, minutes, and seconds (ex: duration'P1DT2H30M')
+//	guid:    guid'xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx' or just the GUID; compares with a [16]byte or a string in GUID form
+//	binary:  binary'<base64>'; compares with a []byte
+//	null     (represents the precense (ne)/absense(eq) of a property)
+//
+// A function call's arguments are expressions; a function used as a value may appear in a comparison.
+// The date and time functions are:
+//
+//	integers:   year(t), month(t), day(t), hour(t), minute(t), second(t), totaloffsetminutes(t)
+//	date parts: date(t) (compare with a yyyy-mm-dd literal), time(t)
+//	now():      the time when Evaluate was called (see WithNow)
+//
+// The math functions are:
+//
+//	round(n), floor(n), ceiling(n): an integer argument is returned unchanged
+//	abs(n), min(n1, n2), max(n1, n2)
+//
+// The property functions distinguish a property whose value is null from one that doesn't exist
+// (eq null is true for both unless WithMissingProperties specifies otherwise):
+//
+//	exists(p), isdefined(p): true if property p exists, even if its value is null
+//
+// The string functions are:
+//
+//	predicates: contains(s, substr), startswith(s, prefix), endswith(s, suffix)
+//	integers:   length(s), indexof(s, substr)
+//	strings:    substring(s, start[, length]), tolower(s), toupper(s), trim(s), concat(s1, s2)
+//
+// If filter is malformed, the error is a *parser.SyntaxError whose Position locates the problem.
+func New(filter string, options ...ParseOption) (Filter, error) {
+	o := parser.Options{}
+	for _, option := range options {
+		option(&o)
+	}
+	root, err := parser.Parse(filter, o)
 	if err != nil {
-		return nil, err
+		return Filter{}, err
+	}
+	showNode(root)
+	return Filter{root: root}, nil
+}
+
+// NewWithDiagnostics parses a filter string like New but, instead of stopping at the first error,
+// it returns every error and warning found in filter. err is the first error diagnostic, if any.
+func NewWithDiagnostics(filter string, options ...ParseOption) (f Filter, diagnostics []parser.Diagnostic, err error) {
+	o := parser.Options{}
+	for _, option := range options {
+		option(&o)
 	}
-	for _, n := range parseNodes {
-		showNode(n)
-		if err := n.Error; err != nil {
-			return nil, err
+	root, diagnostics := parser.ParseDiagnostics(filter, o)
+	for _, d := range diagnostics {
+		if d.Severity == parser.SeverityError {
+			return Filter{}, diagnostics, d.SyntaxError
 		}
 	}
-	return Filter(parseNodes), nil
+	showNode(root)
+	return Filter{root: root}, diagnostics, nil
 }
 
-// Evaluate applies the filter to the value in map m.
-// The each of the map's values must be one of: bool, integer, float, string, time, or a child map (arrays are not supported).
-func (f Filter) Evaluate(m map[string]any) (result bool, err error) {
-	evalStack := collections.Stack[bool]{}
-	for _, node := range f {
-		switch node.NodeKind {
-		case parser.NodeAnd:
-			b1, b2 := evalStack.Pop(), evalStack.Pop()
-			evalStack.Push(b1 && b2)
-
-		case parser.NodeOr:
-			b1, b2 := evalStack.Pop(), evalStack.Pop()
-			evalStack.Push(b1 || b2)
-
-		case parser.NodeContains:
-			// Evaluate this node and push it on the stack
-			jsonVal := getPropValue(node.Contains.PropName, m) // Try to find property value
-			if jsonVal == nil {
-				return false, err // Property doesn't exist, report the error
-			}
-			b, err := node.Contains.Evaluate(jsonVal)
-			if err != nil {
-				return false, err
-			}
-			evalStack.Push(b)
-
-		case parser.NodeComparison:
-			// Evaluate this node and push it on the stack
-			b, err := node.Comparison.Evaluate(getPropValue(node.Comparison.PropName, m))
-			if err != nil {
-				return false, err
-			}
-			evalStack.Push(b)
-		}
+// NewCST parses a filter string like New and also returns its lossless concrete syntax tree, which
+// reproduces filter exactly, including its whitespace and comments, for tools that format or rewrite filters.
+func NewCST(filter string, options ...ParseOption) (Filter, *parser.CST, error) {
+	o := parser.Options{}
+	for _, option := range options {
+		option(&o)
 	}
-	return evalStack.Pop(), nil // Retun the last (and only) value on the stack
+	cst, err := parser.ParseCST(filter, o)
+	if err != nil {
+		return Filter{}, nil, err
+	}
+	showNode(cst.Root.Node)
+	return Filter{root: cst.Root.Node}, cst, nil
+}
+
+// ParseOption customizes how New and NewWithDiagnostics parse a filter.
+type ParseOption func(o *parser.Options)
+
+// WithSchema supplies the kinds of properties' values so that New reports type mismatches
+// involving these properties instead of Evaluate.
+func WithSchema(schema parser.Schema) ParseOption {
+	return func(o *parser.Options) { o.Schema = schema }
+}
+
+// WithCaseInsensitiveKeywords lets keywords, operators, function names, null, true, false, and type names
+// appear in any case: Name EQ 'x' AND Active eq TRUE AND At lt Time'2024-05-01T00:00:00Z'. Property names
+// are still case-sensitive.
+func WithCaseInsensitiveKeywords() ParseOption {
+	return func(o *parser.Options) { o.CaseInsensitive = true }
 }
 
-/*
-type propertyError struct {
-	msg string
+// WithPathSeparators limits the characters that may separate the names in a property path: parser.SlashOnly
+// (as in OData: address/city), parser.PeriodOnly, or parser.SlashOrPeriod (the default). The / before
+// a lambda operator and JSON Pointers are always allowed.
+func WithPathSeparators(separators parser.PathSeparators) ParseOption {
+	return func(o *parser.Options) { o.PathSeparators = separators }
 }
 
-func (e propertyError) Error() string {
-	return e.msg
+// Root returns the root of the filter's expression tree.
+func (f Filter) Root() parser.Node { return f.root }
+
+// String returns the filter's text as reconstructed from its expression tree.
+func (f Filter) String() string { return f.root.String() }
+
+// Evaluate applies the filter to the value in map m.
+// The each of the map's values must be one of: bool, any Go integer or float type, json.Number (a number with
+// a fraction or exponent is a decimal), *big.Int, *big.Float, parser.Decimal or any parser.DecimalNumber (such
+// as a type from another decimal package), string, time, time.Duration, parser.Date, parser.TimeOfDay,
+// parser.GUID or [16]byte, []byte, a child map, or an array (a slice) of these; an array's elements can be
+// tested with the any and all lambda operators or reached with an index. A property that doesn't exist is
+// treated as null unless WithMissingProperties specifies otherwise; a property whose value is nil exists and is null.
+func (f Filter) Evaluate(m map[string]any, options ...EvaluateOption) (result bool, err error) {
+	e := &evaluator{m: m, now: time.Now}
+	for _, o := range options {
+		o(e)
+	}
+	e.env.Now = e.now()
+	return e.evaluateBool(f.root)
 }
-*/
 
-func getPropValue(propName string, json map[string]any) any {
-	var jsonVal any = json
+// getPropValue walks path from json; an array index in path steps into an array (a []any or any other
+// slice or array). ok is false if the property doesn't exist, including if an index is out of range.
+func getPropValue(path []string, json any) (value any, ok bool) {
+	jsonVal := json
 
-	for _, pn := range strings.Split(propName, ".") {
-		if jv, ok := jsonVal.(map[string]any); !ok {
-			// This is not a JSON object; so we can't walk to a child property
-			return nil // propertyError{msg: fmt.Sprintf("Property has no children: '%v'", jsonVal)}
-		} else {
+	for _, pn := range path {
+		if jv, ok := jsonVal.(map[string]any); ok {
 			if jv, ok := jv[pn]; !ok {
-				return nil // propertyError{msg: fmt.Sprintf("Property '%s' not found in '%v'", pn, jsonVal)}
+				return nil, false // Property not found in jsonVal
 			} else {
 				jsonVal = jv // Walk into the child property
 			}
+			continue
 		}
+		if i, ok := parser.ArrayIndex(pn); ok {
+			if jv, ok := getElement(jsonVal, i); ok {
+				jsonVal = jv // Walk into the array element
+				continue
+			}
+		}
+		// This is not a JSON object or an array element; so we can't walk to a child property
+		return nil, false
 	}
-	return jsonVal
+	return jsonVal, true
 }
 
-// Converts in-fix to post-fix (reverse-polish)
-func inFixToPostFix(nodes []parser.Node) ([]parser.Node, error) {
-	// This uses the ShuntingYard algorithm
-	postfixQueue := collections.Queue[parser.Node]{}
-	operatorStack := collections.Stack[parser.Node]{}
-
-NextNode:
-	for _, n := range nodes { // Read tokens (in-fix order)
-		switch n.NodeKind {
-		case parser.NodeEOF:
-			break NextNode
-
-		case parser.NodeError:
-			postfixQueue.Enqueue(n)
-			break NextNode
-
-		case parser.NodeLeftParen, parser.NodeAnd: // If and/or/left-paren, push on stack
-			operatorStack.Push(n)
-
-		case parser.NodeOr: // Or is lower precedence than and, so push on stack
-			// If higher precedence operator is on stack, pop it off and add to queue
-			for {
-				op, ok := operatorStack.Peek()
-				if !ok || op.NodeKind != parser.NodeAnd {
-					break
-				}
-				postfixQueue.Enqueue(operatorStack.Pop())
-			}
-			operatorStack.Push(n) // After moving higher precedence operators to queue, push 'or' on stack
-
-		case parser.NodeRightParen: // If right-paren, pop stack to queue until left-paren (discard both parens)
-			for !operatorStack.Empty() {
-				if n := operatorStack.Pop(); n.NodeKind != parser.NodeLeftParen {
-					postfixQueue.Enqueue(n)
-				} else {
-					break // Discard both parens and stop
-				}
-			}
-
-		default: // Just enqueue any other bool expression
-			postfixQueue.Enqueue(n)
+// getElement returns element i of array (counting back from the end if i is negative);
+// ok is false if array isn't an array or a slice or i is out of range.
+func getElement(array any, i int) (element any, ok bool) {
+	if a, isAny := array.([]any); isAny { // Avoid reflection for the common JSON case
+		if i < 0 {
+			i += len(a)
 		}
+		if i < 0 || i >= len(a) {
+			return nil, false
+		}
+		return a[i], true
+	}
+	if parser.KindOf(array) != parser.KindArray { // A []byte or [16]byte is a binary value or GUID, not an array
+		return nil, false
+	}
+	v := reflect.ValueOf(array)
+	if i < 0 {
+		i += v.Len()
 	}
-	// No more tokens, pop rest of stack to queue
-	for !operatorStack.Empty() {
-		postfixQueue.Enqueue(operatorStack.Pop())
+	if i < 0 || i >= v.Len() {
+		return nil, false
 	}
-	return postfixQueue, nil
+	return v.Index(i).Interface(), true
 }
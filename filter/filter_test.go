@@ -0,0 +1,629 @@
+package filter
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"githib.com/JeffreyRichter/filter/parser"
+)
+
+// doc is the value that most of the tests evaluate filters against.
+var doc = map[string]any{
+	"string": "Jeff", "int": 23, "float": 3.14, "bool": true, "nul": nil,
+	"time": time.Date(1990, time.January, 1, 0, 0, 0, 0, time.UTC),
+	"id":   "0123ABCD-89ab-cdef-0123-456789abcdef", "dur": 26 * time.Hour, "bin": []byte("hello"),
+	"gid":  [16]byte{0x01, 0x23, 0xab, 0xcd, 0x89, 0xab, 0xcd, 0xef, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef},
+	"date": parser.Date{Year: 2024, Month: time.May, Day: 1}, "tod": parser.TimeOfDay(13*time.Hour + 45*time.Minute),
+	"child": map[string]any{"childString": "child", "childBool": false, "childInt": 42},
+}
+
+// evaluateTest is a filter and the result of evaluating it; err is true if New or Evaluate must fail.
+type evaluateTest struct {
+	filter string
+	want   bool
+	err    bool
+}
+
+// testEvaluate parses each test's filter and evaluates it against m with options.
+func testEvaluate(t *testing.T, m map[string]any, tests []evaluateTest, options ...EvaluateOption) {
+	t.Helper()
+	for _, tt := range tests {
+		f, err := New(tt.filter)
+		if err == nil {
+			var got bool
+			if got, err = f.Evaluate(m, options...); err == nil && got != tt.want {
+				t.Errorf("%s: got %v, want %v", tt.filter, got, tt.want)
+			}
+		}
+		if (err != nil) != tt.err {
+			t.Errorf("%s: err = %v, want error: %v", tt.filter, err, tt.err)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	testEvaluate(t, doc, []evaluateTest{
+		{filter: "string eq 'Jeff'", want: true},
+		{filter: "string ne 'Jeff'", want: false},
+		{filter: "int gt 22 and int lt 24", want: true},
+		{filter: "int ge 23 and int le 23", want: true},
+		{filter: "float gt 3 and float lt 3.5", want: true},
+		{filter: "bool eq true", want: true},
+		{filter: "child.childInt eq 42 and child.childBool eq false", want: true},
+		{filter: "time gt time'1989-01-01T00:00:00Z'", want: true},
+		{filter: "nope eq null and int ne null", want: true},
+		{filter: "nope gt 5", want: false},
+		{filter: "int eq 1 or string eq 'Jeff' and bool eq true", want: true},
+		{filter: "(int eq 1 or string eq 'Jeff') and bool eq false", want: false},
+		{filter: "contains(string, 'ef')", want: true},
+		{filter: "int eq 'x'", err: true},
+		{filter: "int gt null", err: true},
+		{filter: "int eq 23)", err: true},
+	})
+}
+
+func TestEvaluateNot(t *testing.T) {
+	testEvaluate(t, doc, []evaluateTest{
+		{filter: "not int eq 23", want: false},
+		{filter: "not (int eq 23) or bool eq true", want: true},
+		{filter: "not int eq 1 and bool eq false", want: false},
+		{filter: "not contains(string, 'ef')", want: false},
+		{filter: "not not contains(string, 'ef') and not int eq 2", want: true},
+		{filter: "not(int eq 1 or int eq 2)", want: true},
+		{filter: "not (int)", err: true},
+	})
+}
+
+func TestEvaluateArithmetic(t *testing.T) {
+	testEvaluate(t, doc, []evaluateTest{
+		{filter: "int add 1 eq 24 and int sub 3 eq 20", want: true},
+		{filter: "int mul 2 eq 46 and int add 1 mul 2 eq 25", want: true},
+		{filter: "(int add 1) mul 2 eq 48", want: true},
+		{filter: "int div 2 eq 11 and int divby 2 eq 11.5 and int mod 2 eq 1", want: true},
+		{filter: "int sub 3 sub 10 eq 10", want: true},
+		{filter: "float mul int gt 72", want: true},
+		{filter: "int eq 20 add 3", want: true},
+		{filter: "nope add 1 eq 2", want: false},
+		{filter: "int div 0 eq 1", err: true},
+		{filter: "int mod 0 eq 1", err: true},
+		{filter: "int mul 9223372036854775807 eq 1", err: true},
+		{filter: "int sub -9223372036854775807 sub 9223372036854775807 eq 1", err: true},
+		{filter: "-9223372036854775807 sub 2 eq int", err: true},
+		{filter: "string add 1 eq 2", err: true},
+	})
+}
+
+func TestEvaluateIn(t *testing.T) {
+	testEvaluate(t, doc, []evaluateTest{
+		{filter: "int in (1, 23, 5)", want: true},
+		{filter: "int in (1, 23.0)", want: true},
+		{filter: "float in (3.14, 2)", want: true},
+		{filter: "float in (3, 2)", want: false},
+		{filter: "string in ('a', 'Jeff')", want: true},
+		{filter: "not string in ('a', 'Jeff')", want: false},
+		{filter: "nope in (1, 2)", want: false},
+		{filter: "time in (time'1990-01-01T00:00:00Z')", want: true},
+		{filter: "time in (time'1990-01-01T01:00:00+01:00')", want: true},
+		{filter: "int add 1 in (24) and bool eq true", want: true},
+		{filter: "'Jeff' in ('Jeff')", want: true},
+		{filter: "string in (1, 2)", err: true},
+		{filter: "string in ('a', 1)", err: true},
+	})
+}
+
+func TestEvaluateStringFunctions(t *testing.T) {
+	m := map[string]any{"s": "Jeff", "city": "São Paulo", "padded": "  x  "}
+	testEvaluate(t, m, []evaluateTest{
+		{filter: "contains(s, 'ef') and startswith(s, 'Je') and endswith(s, 'ff')", want: true},
+		{filter: "contains(s, 'x') or startswith(s, 'ef') or endswith(s, 'Je')", want: false},
+		{filter: "length(s) eq 4 and length(city) eq 9", want: true},
+		{filter: "length(s) add 1 eq 5", want: true},
+		{filter: "indexof(s, 'ff') eq 2 and indexof(s, 'x') eq -1 and indexof(city, 'P') eq 4", want: true},
+		{filter: "substring(s, 1) eq 'eff' and substring(s, 1, 2) eq 'ef' and substring(s, 10, 2) eq ''", want: true},
+		{filter: "substring(city, 2, 3) eq 'o P'", want: true},
+		{filter: "tolower(s) eq 'jeff' and toupper(s) eq 'JEFF' and trim(padded) eq 'x'", want: true},
+		{filter: "concat(s, city) eq 'JeffSão Paulo'", want: true},
+		{filter: "contains(tolower(s), 'je')", want: true},
+		{filter: "length(nope) eq 3 or contains(nope, 'x')", want: false},
+		{filter: "length(s) eq 3", want: false},
+		{filter: "length(s)", err: true},
+		{filter: "substring(s)", err: true},
+		{filter: "substring(s, 1, 2, 3) eq ''", err: true},
+		{filter: "contains(s)", err: true},
+	})
+	testEvaluate(t, map[string]any{"n": 3}, []evaluateTest{{filter: "length(n) eq 3", err: true}})
+}
+
+func TestEvaluateDateTimeFunctions(t *testing.T) {
+	now := time.Date(2030, time.June, 15, 12, 0, 0, 0, time.UTC)
+	m := map[string]any{
+		"t":       time.Date(1990, time.February, 3, 4, 5, 6, 0, time.UTC),
+		"local":   time.Date(1990, time.February, 3, 4, 5, 6, 0, time.FixedZone("", -90*60)),
+		"created": now.Add(-30 * time.Minute),
+	}
+	testEvaluate(t, m, []evaluateTest{
+		{filter: "year(t) eq 1990 and month(t) eq 2 and day(t) eq 3", want: true},
+		{filter: "hour(t) eq 4 and minute(t) eq 5 and second(t) eq 6", want: true},
+		{filter: "date(t) eq 1990-02-03 and date(t) lt 1990-02-04 and date(t) gt 1990-02-02", want: true},
+		{filter: "time(t) eq 04:05:06", want: true},
+		{filter: "year(date(t)) eq 1990 and hour(time(t)) eq 4", want: true},
+		{filter: "totaloffsetminutes(t) eq 0 and totaloffsetminutes(local) eq -90", want: true},
+		{filter: "t lt now() and year(now()) eq 2030 and month(now()) eq 6", want: true},
+		{filter: "created gt now() sub duration'PT1H' and t lt now() sub duration'PT1H'", want: true},
+		{filter: "now() sub created eq duration'PT30M' and created add duration'PT30M' eq now()", want: true},
+		{filter: "year(nope) eq 1990", want: false},
+		{filter: "year(t) eq 1990 and now() eq 1", err: true},
+		{filter: "year('x') eq 1990", err: true},
+		{filter: "date(t) eq 1990-13-01", err: true},
+	}, WithNow(func() time.Time { return now }))
+}
+
+func TestEvaluateMathFunctions(t *testing.T) {
+	m := map[string]any{"i": 23, "f": 3.14, "neg": -2.5, "nan": math.NaN()}
+	testEvaluate(t, m, []evaluateTest{
+		{filter: "round(f) eq 3 and floor(f) eq 3 and ceiling(f) eq 4", want: true},
+		{filter: "round(neg) eq -3 and floor(neg) eq -3 and ceiling(neg) eq -2", want: true},
+		{filter: "round(i) eq 23 and floor(i) eq 23 and ceiling(i) eq 23", want: true},
+		{filter: "round(f mul 10) eq 31", want: true},
+		{filter: "abs(i sub 30) eq 7 and abs(neg) eq 2.5 and abs(f) eq f", want: true},
+		{filter: "min(i, f) eq 3.14 and max(i, f) eq 23 and min(i, 4) eq 4 and max(-1, -2) eq -1", want: true},
+		{filter: "min(i, nope) eq 1", want: false},
+		{filter: "min(nan, 1) ne min(nan, 1) and max(1, nan) ne max(1, nan)", want: true},
+		{filter: "min(nan, 1.5) ne min(nan, 1.5) and max(1.5, nan) ne max(1.5, nan)", want: true},
+		{filter: "min(nan, 1) eq 1 or max(nan, 1) eq 1 or min(1, nan) eq 1", want: false},
+		{filter: "abs(-9223372036854775807 sub 1) eq 1", err: true},
+		{filter: "abs('x') eq 1", err: true},
+		{filter: "min(1) eq 1", err: true},
+	})
+}
+
+func TestEvaluateLambda(t *testing.T) {
+	m := map[string]any{
+		"int": 23, "tags": []any{"urgent", "red"}, "strs": []string{"a", "b"}, "empty": []any{},
+		"items": []any{
+			map[string]any{"price": 10, "tags": []any{"x"}},
+			map[string]any{"price": 50, "tags": []any{"y", "z"}},
+		},
+	}
+	testEvaluate(t, m, []evaluateTest{
+		{filter: "tags/any(t: t eq 'urgent')", want: true},
+		{filter: "tags/all(t: t eq 'urgent')", want: false},
+		{filter: "strs/all(s: length(s) eq 1)", want: true},
+		{filter: "items/all(i: i/price lt 100)", want: true},
+		{filter: "items/any(i: i/price lt int)", want: true},
+		{filter: "items/any(i: i/price gt 20 and i/tags/any(t: t eq 'z'))", want: true},
+		{filter: "items/any(i: i/tags/all(t: t eq 'x'))", want: true},
+		{filter: "items/all(i: i/tags/all(t: t eq 'x'))", want: false},
+		{filter: "empty/any() or empty/any(e: e eq 1)", want: false},
+		{filter: "empty/all(e: e eq 1)", want: true},
+		{filter: "tags/any() and not nope/any()", want: true},
+		{filter: "not tags/any(t: t eq 'blue')", want: true},
+		{filter: "items/any(i: i/price eq 10) and i eq 1", want: false},
+		{filter: "int/any()", err: true},
+	})
+}
+
+func TestEvaluatePropertyComparison(t *testing.T) {
+	m := map[string]any{"int": 23, "int32": int32(23), "quota": 100, "used": 50.5, "n": nil, "s": "Jeff",
+		"child": map[string]any{"childInt": 42}}
+	testEvaluate(t, m, []evaluateTest{
+		{filter: "used le quota and quota gt used", want: true},
+		{filter: "int eq int32 and int32 eq int and int eq int", want: true},
+		{filter: "int eq child/childInt sub 19", want: true},
+		{filter: "int ne nope and nope ne int", want: true},
+		{filter: "int eq nope or nope eq int", want: false},
+		{filter: "int ne n and n ne int", want: true},
+		{filter: "n eq nope and nope eq n and n eq n", want: true},
+		{filter: "n ne nope or nope ne n", want: false},
+		{filter: "int gt nope or nope gt int or nope le n", want: false},
+		{filter: "int eq s", err: true},
+	})
+}
+
+func TestEvaluateLiteralOnLeft(t *testing.T) {
+	testEvaluate(t, doc, []evaluateTest{
+		{filter: "5 lt int and 5 le int and 30 gt int and 30 ge int", want: true},
+		{filter: "5 ge int", want: false},
+		{filter: "'Jeff' eq string", want: true},
+		{filter: "null eq nope and null ne int", want: true},
+		{filter: "1 eq 1 and 2 mul 10 lt int add 0", want: true},
+		{filter: "null lt nope", err: true},
+	})
+}
+
+func TestEvaluateStringLiterals(t *testing.T) {
+	m := map[string]any{"name": "Jeff Richter", "city": "São Paulo", "title": "O'Brien", "emoji": "日本 🎉", "quote": "'"}
+	testEvaluate(t, m, []evaluateTest{
+		{filter: "name eq 'Jeff Richter' and city eq 'São Paulo'", want: true},
+		{filter: "title eq 'O''Brien' and 'O''Brien' eq title", want: true},
+		{filter: "emoji eq '日本 🎉' and quote eq ''''", want: true},
+		{filter: "contains(name, ' ') and city in ('São Paulo', 'a b')", want: true},
+		{filter: "name eq ''", want: false},
+		{filter: "title eq 'O''Brien", err: true},
+		{filter: "title eq 'abc", err: true},
+	})
+}
+
+func TestNewWithDiagnostics(t *testing.T) {
+	f, diagnostics, err := NewWithDiagnostics("int eq 23 and 1 eq 1")
+	if err != nil || len(diagnostics) != 1 || diagnostics[0].Severity != parser.SeverityWarning {
+		t.Errorf("got %v, %v; want one warning", diagnostics, err)
+	} else if got, err := f.Evaluate(doc); !got || err != nil {
+		t.Errorf("Evaluate = %v, %v; want true", got, err)
+	}
+	_, diagnostics, err = NewWithDiagnostics("int eq 1 and ! eq 2 or # eq 3")
+	if len(diagnostics) != 2 || err != diagnostics[0].SyntaxError {
+		t.Errorf("got %v, %v; want two errors, returning the first", diagnostics, err)
+	}
+}
+
+func TestEvaluateNumericLiterals(t *testing.T) {
+	testEvaluate(t, doc, []evaluateTest{
+		{filter: "int eq 2.3e1 and int eq 23L and int eq 23l and int eq 23m", want: true},
+		{filter: "float eq 3.14d and float eq 3.14m and float le 314E-2f", want: true},
+		{filter: "float lt 314E-2f", want: false},
+		{filter: "float gt .5 and float gt -.5 and int sub -1 eq 24", want: true},
+		{filter: "float lt INF and float gt -INF", want: true},
+		{filter: "float eq NaN", want: false},
+		{filter: "float ne NaN", want: true},
+		{filter: "int eq 1e400", err: true},
+		{filter: "int eq 5days", err: true},
+		{filter: "int eq 1.5L", err: true},
+	})
+}
+
+func TestEvaluateTypedLiterals(t *testing.T) {
+	testEvaluate(t, doc, []evaluateTest{
+		{filter: "id eq guid'0123abcd-89ab-cdef-0123-456789abcdef' and id eq 0123abcd-89ab-cdef-0123-456789abcdef", want: true},
+		{filter: "gid eq 0123abcd-89ab-cdef-0123-456789abcdef and gid eq id", want: true},
+		{filter: "id in (0123abcd-89ab-cdef-0123-456789abcdee, 0123abcd-89ab-cdef-0123-456789abcdef)", want: true},
+		{filter: "id eq guid'0123abcd'", err: true},
+		{filter: "dur eq duration'P1DT2H' and dur gt duration'PT25H59M59.5S' and dur lt duration'P2D'", want: true},
+		{filter: "dur eq duration'-P1D'", want: false},
+		{filter: "dur eq duration'P1Y'", err: true},
+		{filter: "dur eq duration'PT'", err: true},
+		{filter: "dur eq 26", err: true},
+		{filter: "date eq 2024-05-01 and date lt 2024-05-02 and date gt 2023-12-31", want: true},
+		{filter: "date eq 2024-13-01", err: true},
+		{filter: "tod eq 13:45:00 and tod eq 13:45 and tod lt 13:45:00.5", want: true},
+		{filter: "tod eq 25:00", err: true},
+		{filter: "bin eq binary'aGVsbG8=' and bin eq binary'aGVsbG8' and bin ne binary'aGVsbA'", want: true},
+		{filter: "bin eq binary'!!'", err: true},
+		{filter: "time add duration'P1D' eq time'1990-01-02T00:00:00Z'", want: true},
+		{filter: "dur add duration'PT1H' eq dur", want: false},
+		{filter: "dur sub duration'PT2H' eq duration'P1D' and time sub time'1989-12-31T00:00:00Z' eq duration'P1D'", want: true},
+		{filter: "time add time eq time", err: true},
+	})
+}
+
+func TestCaseInsensitiveKeywords(t *testing.T) {
+	f, err := New("String EQ 'Jeff' OR string EQ 'Jeff' AND time LT Time'2000-01-01T00:00:00Z'", WithCaseInsensitiveKeywords())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := f.Evaluate(doc); err != nil || !got {
+		t.Errorf("%s: got %v, %v; want true", f, got, err)
+	}
+}
+
+func TestEvaluateWhitespaceAndComments(t *testing.T) {
+	testEvaluate(t, doc, []evaluateTest{
+		{filter: "string eq 'Jeff'\n\tand int gt 22 -- adults only\r\n\tand /* skip: int lt 0 and */ bool eq true", want: true},
+		{filter: "string eq 'Jeff' // or int eq 1\n", want: true},
+		{filter: "string eq 'Jeff' and\n -- int eq 1\n int eq 2", want: false},
+		{filter: "string eq 'Jeff' /* and", err: true},
+	})
+}
+
+func TestEvaluateQuotedNamesAndPointers(t *testing.T) {
+	m := map[string]any{
+		"odd.key": map[string]any{"child": 7}, "k8s.io/name": "web", "a~b": 1, "my key": "x", "café": 2, "say \"hi\"": 3,
+		"and": 4, "x": 5, "tags": []any{5, 6},
+	}
+	testEvaluate(t, m, []evaluateTest{
+		{filter: `"odd.key".child eq 7 and "odd.key"/child eq 7 and /odd.key/child eq 7`, want: true},
+		{filter: `/k8s.io~1name eq 'web' and /a~0b eq 1 and "a~b" eq 1`, want: true},
+		{filter: `"my key" eq 'x' and café eq 2 and "say ""hi""" eq 3 and "and" eq 4`, want: true},
+		{filter: `tags/any(x: /x eq 5 and x eq 7)`, want: false},
+		{filter: `tags/any(x: /x eq 5 and x eq 5)`, want: true},
+		{filter: `tags/all(x: "x" eq 5)`, want: true},
+		{filter: `/a~2b eq 1`, err: true},
+	})
+}
+
+func TestEvaluatePathsAndIndexes(t *testing.T) {
+	m := map[string]any{
+		"address": map[string]any{"city": "Redmond"}, "strs": []string{"a", "b"}, "arr": [2]int{1, 2}, "nums": map[string]any{"0": 7},
+		"items": []any{
+			map[string]any{"price": 10, "tags": []any{"x"}},
+			map[string]any{"price": 50, "tags": []any{"y", "z"}},
+		},
+	}
+	testEvaluate(t, m, []evaluateTest{
+		{filter: "address/city eq 'Redmond' and address.city eq 'Redmond'", want: true},
+		{filter: "items/0/price eq 10 and items[1].price eq 50 and items[-1].price eq 50 and items.-2.price eq 10", want: true},
+		{filter: "items/1/tags/1 eq 'z' and items[1].tags[-1] eq 'z' and items[1]/tags[0] eq 'y'", want: true},
+		{filter: "items[2].price eq null and items[-3].price eq null and items/5 eq null", want: true},
+		{filter: "strs[1] eq 'b' and strs/0 eq 'a' and arr[1] eq 2 and address[0] eq null", want: true},
+		{filter: "nums/0 eq 7 and \"nums\".\"0\" eq 7 and nums[0] eq 7", want: true},
+		{filter: "items[0]/tags/any(t: t eq 'x') and items/any(i: i/tags[1] eq 'z')", want: true},
+		{filter: "items/007 eq 1", want: false},
+	})
+	for _, tt := range []struct {
+		separators parser.PathSeparators
+		filter     string
+		err        bool
+	}{
+		{parser.SlashOnly, "address/city eq 'Redmond'", false},
+		{parser.SlashOnly, "address.city eq 'Redmond'", true},
+		{parser.PeriodOnly, "address.city eq 'Redmond' and items/any(i: i.price eq 10)", false},
+		{parser.PeriodOnly, "address/city eq 'Redmond'", true},
+		{parser.SlashOrPeriod, "address/city eq 'Redmond' and address.city eq 'Redmond'", false},
+	} {
+		if _, err := New(tt.filter, WithPathSeparators(tt.separators)); (err != nil) != tt.err {
+			t.Errorf("%s: err = %v, want error: %v", tt.filter, err, tt.err)
+		}
+	}
+}
+
+func TestEvaluateThreeValuedLogic(t *testing.T) {
+	// truth returns T, F, or U (unknown): an unknown filter and its negation both evaluate to false.
+	truth := func(filter string) string {
+		f, err := New(filter)
+		nf, err2 := New("not (" + filter + ")")
+		if err != nil || err2 != nil {
+			t.Fatalf("%s: %v, %v", filter, err, err2)
+		}
+		result, err := f.Evaluate(doc, WithThreeValuedLogic())
+		negated, err2 := nf.Evaluate(doc, WithThreeValuedLogic())
+		switch {
+		case err != nil || err2 != nil:
+			t.Fatalf("%s: %v, %v", filter, err, err2)
+		case result && !negated:
+			return "T"
+		case !result && negated:
+			return "F"
+		case !result && !negated:
+			return "U"
+		}
+		t.Fatalf("%s and its negation are both true", filter)
+		return ""
+	}
+	values := map[string]string{"T": "int eq 23", "F": "int eq 1", "U": "nope eq 1"}
+	for _, tt := range []struct{ l, r, and, or string }{
+		{"T", "T", "T", "T"}, {"T", "F", "F", "T"}, {"T", "U", "U", "T"},
+		{"F", "T", "F", "T"}, {"F", "F", "F", "F"}, {"F", "U", "F", "U"},
+		{"U", "T", "U", "T"}, {"U", "F", "F", "U"}, {"U", "U", "U", "U"},
+	} {
+		l, r := values[tt.l], values[tt.r]
+		if got := truth(l + " and " + r); got != tt.and {
+			t.Errorf("%s and %s = %s, want %s", tt.l, tt.r, got, tt.and)
+		}
+		if got := truth(l + " or " + r); got != tt.or {
+			t.Errorf("%s or %s = %s, want %s", tt.l, tt.r, got, tt.or)
+		}
+	}
+	for filter, want := range map[string]string{
+		"not (nope eq 1)": "U", "nope eq null": "T", "nope ne null": "F", "nul eq null": "T", "nope eq int": "U",
+		"contains(nope, 'x')": "U", "nope in (1, 2)": "U", "length(nope) eq 3": "U", "nope add 1 gt 3": "U",
+		"nope add 1 eq null": "T", "nope/any(x: x eq 1)": "U",
+	} {
+		if got := truth(filter); got != want {
+			t.Errorf("%s = %s, want %s", filter, got, want)
+		}
+	}
+
+	m := map[string]any{"items": []any{map[string]any{"price": 10}, map[string]any{"qty": 1}}}
+	for _, tt := range []struct {
+		filter     string
+		two, three bool // The result with two-valued and three-valued logic
+	}{
+		{"not (nope gt 5)", true, false},
+		{"not (nope gt 5) or items/any()", true, true},
+		{"not (nope gt 5 and items/any())", true, false},
+		{"items/any(i: i/price gt 5)", true, true},
+		{"not items/any(i: i/price gt 50)", true, false},
+		{"not items/all(i: i/price gt 5)", true, false},
+		{"not items/all(i: i/price gt 50)", true, true},
+		{"items/any(i: i/price gt 50 or i/qty eq 1)", true, true},
+	} {
+		f, err := New(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := f.Evaluate(m); err != nil || got != tt.two {
+			t.Errorf("%s: got %v, %v; want %v", tt.filter, got, err, tt.two)
+		}
+		if got, err := f.Evaluate(m, WithThreeValuedLogic()); err != nil || got != tt.three {
+			t.Errorf("%s with three-valued logic: got %v, %v; want %v", tt.filter, got, err, tt.three)
+		}
+	}
+}
+
+func TestEvaluateMissingProperties(t *testing.T) {
+	m := map[string]any{"int": 23, "nul": nil, "items": []any{map[string]any{"price": 10}, map[string]any{"qty": 1}},
+		"orders": []any{map[string]any{"lines": []any{map[string]any{"sku": "a"}, map[string]any{}}}}}
+	for _, tt := range []struct {
+		filter          string
+		isNull, isFalse bool   // The result with MissingIsNull and MissingIsFalse
+		err             string // The error with MissingIsError; if "", the result is the same as with MissingIsNull
+	}{
+		{"nope eq null", true, false, "Property not found: nope"},
+		{"nope ne null", false, false, "Property not found: nope"},
+		{"nope ne 5", true, false, "Property not found: nope"},
+		{"not (nope gt 5)", true, true, "Property not found: nope"},
+		{"nul eq null and not (nul gt 5)", true, true, ""},
+		{"contains(nope, 'x')", false, false, "Property not found: nope"},
+		{"length(nope) eq 3", false, false, "Property not found: nope"},
+		{"nope add 1 eq null", true, false, "Property not found: nope"},
+		{"nope in (1)", false, false, "Property not found: nope"},
+		{"nope/any()", false, false, "Property not found: nope"},
+		{"int.x eq 1", false, false, "Property not found: int.x"},
+		{"items[5].price eq 1", false, false, "Property not found: items[5].price"},
+		{"items/all(i: i/price gt 5)", false, false, "Property not found: items[1].price"},
+		{"orders/any(o: o/lines/any(l: l/sku eq 'b'))", false, false, "Property not found: orders[0].lines[1].sku"},
+		{"int eq 23 or child.nope.deep eq 1", true, true, ""},
+		{"int eq 1 or child.nope.deep eq 1", false, false, "Property not found: child.nope.deep"},
+	} {
+		f, err := New(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := f.Evaluate(m, WithMissingProperties(MissingIsNull)); err != nil || got != tt.isNull {
+			t.Errorf("%s with MissingIsNull: got %v, %v; want %v", tt.filter, got, err, tt.isNull)
+		}
+		if got, err := f.Evaluate(m); err != nil || got != tt.isNull {
+			t.Errorf("%s by default: got %v, %v; want %v", tt.filter, got, err, tt.isNull)
+		}
+		if got, err := f.Evaluate(m, WithMissingProperties(MissingIsFalse)); err != nil || got != tt.isFalse {
+			t.Errorf("%s with MissingIsFalse: got %v, %v; want %v", tt.filter, got, err, tt.isFalse)
+		}
+		got, err := f.Evaluate(m, WithMissingProperties(MissingIsError))
+		if tt.err == "" && (err != nil || got != tt.isNull) {
+			t.Errorf("%s with MissingIsError: got %v, %v; want %v", tt.filter, got, err, tt.isNull)
+		}
+		if tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%s with MissingIsError: err = %v, want %q", tt.filter, err, tt.err)
+		}
+	}
+}
+
+func TestEvaluateExists(t *testing.T) {
+	m := map[string]any{"int": 23, "nul": nil, "items": []any{map[string]any{"price": nil}, map[string]any{}}}
+	tests := []evaluateTest{
+		{filter: "exists(nul) and nul eq null", want: true},
+		{filter: "exists(nope)", want: false},
+		{filter: "isdefined(int) and exists(items[1]) and not isdefined(items[2])", want: true},
+		{filter: "exists(items[0].price) and not exists(items[1].price) and exists(/items/0/price)", want: true},
+		{filter: "items/any(i: exists(i/price))", want: true},
+		{filter: "items/all(i: exists(i/price))", want: false},
+		{filter: "exists(1)", err: true},
+		{filter: "exists(int add 1)", err: true},
+		{filter: "exists(items/any())", err: true},
+		{filter: "exists()", err: true},
+		{filter: "exists(int, nul)", err: true},
+	}
+	// exists never treats a missing property as null, unknown, or an error
+	testEvaluate(t, m, tests)
+	testEvaluate(t, m, tests, WithMissingProperties(MissingIsFalse))
+	testEvaluate(t, m, tests, WithMissingProperties(MissingIsError))
+	testEvaluate(t, m, tests, WithThreeValuedLogic())
+	testEvaluate(t, m, []evaluateTest{
+		{filter: "not exists(nope) and nope eq null", want: false},
+	}, WithMissingProperties(MissingIsFalse))
+}
+
+// cents is a named numeric type, which Evaluate treats like its underlying type.
+type cents int64
+
+func TestEvaluateNumericPromotion(t *testing.T) {
+	bi, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	m := map[string]any{
+		"u64max": uint64(math.MaxUint64), "u8": uint8(7), "i64max": int64(math.MaxInt64), "f": 3.5, "i": 3,
+		"p53": int64(1<<53 + 1), "fp53": float64(1 << 53), "jn": json.Number("42"), "jf": json.Number("19.99"),
+		"jbig": json.Number("18446744073709551616"), "bi": bi, "bf": big.NewFloat(2.5), "nan": math.NaN(),
+		"c64": complex(1, 2), "f32": float32(0.5), "cents": cents(1999), "dur": time.Hour, "month": time.May,
+		"tod": parser.TimeOfDay(time.Hour),
+	}
+	testEvaluate(t, m, []evaluateTest{
+		{filter: "u64max eq 18446744073709551615 and u64max gt i64max and i64max lt u64max", want: true},
+		{filter: "u64max eq 18446744073709551616", want: false},
+		{filter: "u8 gt i and u8 eq 7", want: true},
+		{filter: "i lt 3.5 and i lt f and f gt i and i eq 3.0", want: true},
+		{filter: "p53 ne fp53 and p53 gt fp53 and p53 eq 9007199254740993", want: true},
+		{filter: "jn eq 42 and jn gt 41.5 and jf eq 19.99", want: true},
+		{filter: "jbig eq 18446744073709551616 and jbig gt u64max and u64max add 1 eq jbig", want: true},
+		{filter: "bi eq 123456789012345678901234567890 and bi add 1 eq 123456789012345678901234567891", want: true},
+		{filter: "bi mod 7 eq 123456789012345678901234567890 mod 7 and bi sub bi eq 0", want: true},
+		{filter: "bf eq 2.5 and bf mul 2 eq 5 and bf lt i", want: true},
+		{filter: "round(bf) eq 3 and abs(bf sub 5) eq 2.5 and max(bf, bi) eq bi", want: true},
+		{filter: "f32 eq 0.5 and nan ne 1", want: true},
+		{filter: "nan eq nan", want: false},
+		{filter: "cents eq 1999 and cents gt 19.98 and cents mul 2 eq 3998 and cents in (1999, 2999)", want: true},
+		{filter: "month eq 5 and month in (5)", want: true},
+		{filter: "i64max add 1 eq 0", err: true},
+		{filter: "bi div 0 eq 1", err: true},
+		{filter: "c64 eq 1", err: true},
+		{filter: "dur eq 3600000000000", err: true},
+		{filter: "dur in (3600000000000)", err: true},
+		{filter: "tod eq 3600000000000", err: true},
+	})
+}
+
+func TestEvaluateInIsExact(t *testing.T) {
+	m := map[string]any{
+		"i": int64(9007199254740993), "j": int64(9007199254740992), "f": 0.1, "half": 0.5, "d": parser.NewDecimal(big.NewInt(1), -1),
+		"u": uint64(math.MaxUint64), "bf": new(big.Float).SetInt64(9007199254740993), "inf": math.Inf(1), "nan": math.NaN(),
+	}
+	testEvaluate(t, m, []evaluateTest{
+		{filter: "i in (9007199254740992e0)", want: false},
+		{filter: "i eq 9007199254740992e0", want: false},
+		{filter: "j in (9007199254740992e0, 1.5)", want: true},
+		{filter: "i in (9007199254740993, 1.5)", want: true},
+		{filter: "i in (9007199254740992e0, 0.1)", want: false},
+		{filter: "bf in (9007199254740993, 1e0)", want: true},
+		{filter: "bf in (9007199254740992e0)", want: false},
+		{filter: "u in (18446744073709551615, 1e0)", want: true},
+		{filter: "u in (18446744073709551616e0)", want: false},
+		{filter: "f in (0.1) and f eq 0.1", want: true},
+		{filter: "f in (0.1e0, 0.2e0) and d in (0.1e0) and d eq 0.1e0", want: true},
+		{filter: "d in (0.10, 2) and half in (0.50, 2)", want: true},
+		{filter: "d in (0.1000000000000000000001)", want: false},
+		{filter: "inf in (INF) and not (inf in (1e308))", want: true},
+		{filter: "nan in (NaN)", want: false},
+	})
+}
+
+// otherDecimal stands in for another package's decimal type, such as github.com/shopspring/decimal's.
+type otherDecimal struct {
+	coefficient int64
+	exponent    int32
+}
+
+func (d otherDecimal) Coefficient() *big.Int { return big.NewInt(d.coefficient) }
+func (d otherDecimal) Exponent() int32       { return d.exponent }
+
+func TestEvaluateDecimals(t *testing.T) {
+	a, b := 0.1, 0.2 // Variables so that a add b is float64 arithmetic rather than an exact constant
+	m := map[string]any{
+		"price": json.Number("19.99"), "pstr": "19.99", "f": a, "sum": a + b, "i": 3,
+		"other": otherDecimal{1999, -2}, "big": json.Number("1e400"), "qty": json.Number("3"), "bad": "abc",
+		"half": json.Number("2.5"), "neg": json.Number("-2.5"), "tiny": json.Number("0.49999999999999999999999"),
+	}
+	testEvaluate(t, m, []evaluateTest{
+		{filter: "price eq 19.99 and price eq 19.990 and price ne 19.98", want: true},
+		{filter: "0.1 add 0.2 eq 0.3", want: true},
+		{filter: "f add 0.2 eq 0.3", want: false},
+		{filter: "f eq 0.1 and f eq 0.1m and sum ne 0.3 and sum eq 0.30000000000000004", want: true},
+		{filter: "pstr eq 19.99 and 19.99 eq pstr and pstr gt 19.9", want: true},
+		{filter: "pstr in (19.99, 5.00) and pstr in (20, 19.990) and not (pstr in (1.5))", want: true},
+		{filter: "pstr eq 19", err: true},
+		{filter: "pstr in (19, 20)", err: true},
+		{filter: "bad eq 19.99", err: true},
+		{filter: "bad in (19.99)", err: true},
+		{filter: "other eq 19.99 and other eq price and other mul qty eq 59.97", want: true},
+		{filter: "price mul 3 eq 59.97 and price mul 3 eq 59.970", want: true},
+		{filter: "1.0 div 3 mul 3 eq 0.9999999999999999999999999999999999", want: true},
+		{filter: "1.0 div 4 eq 0.25 and 1 divby 4 eq 0.25", want: true},
+		{filter: "7.5 mod 2 eq 1.5 and -7.5 mod 2 eq -1.5", want: true},
+		{filter: "1.5 div 0 eq 1", err: true},
+		{filter: "big gt 1e300", want: true},
+		{filter: "price in (19.99, 5.00) and qty in (3.0, 4.5) and f in (0.1, 0.2)", want: true},
+		{filter: "price in (19.99d) and not (price in (1.5, 2.5d))", want: true},
+		{filter: "round(half) eq 3 and round(neg) eq -3 and floor(neg) eq -3 and ceiling(neg) eq -2", want: true},
+		{filter: "round(tiny) eq 0 and ceiling(tiny) eq 1", want: true},
+		{filter: "abs(neg) eq 2.5 and min(price, 20) eq 19.99 and max(price, f) eq price", want: true},
+		{filter: "i eq 3.0 and i lt 3.01 and i gt 2.99999999999999999999999", want: true},
+	})
+	for _, filter := range []string{"pstr eq 19.99", "pstr in (19.99)", "pstr in (20, 19.99)"} {
+		if _, err := New(filter, WithSchema(parser.Schema{"pstr": parser.KindString})); err != nil {
+			t.Errorf("%s: %v", filter, err)
+		}
+	}
+	if _, err := New("pstr in (19, 20)", WithSchema(parser.Schema{"pstr": parser.KindString})); err == nil {
+		t.Errorf("pstr in (19, 20): want a type mismatch")
+	}
+}
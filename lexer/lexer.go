@@ -2,7 +2,9 @@ package lexer
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -14,21 +16,56 @@ var showToken = func(t Token) {}
 type TokenKind string
 
 const (
-	TokenError      = TokenKind("Error")
-	TokenEOF        = TokenKind("EOF")
-	TokenWhitespace = TokenKind("Whitespace")
-	TokenLeftParen  = TokenKind("(")
-	TokenRightParen = TokenKind(")")
-	TokenComma      = TokenKind("Comma")
-	TokenSymbol     = TokenKind("Symbol")
-	TokenNumber     = TokenKind("Number")
+	TokenError        = TokenKind("Error")
+	TokenEOF          = TokenKind("EOF")
+	TokenWhitespace   = TokenKind("Whitespace") // Only appears in a token's Trivia
+	TokenComment      = TokenKind("Comment")    // Only appears in a token's Trivia: // line, -- line, or /* block */
+	TokenLeftParen    = TokenKind("(")
+	TokenRightParen   = TokenKind(")")
+	TokenComma        = TokenKind("Comma")
+	TokenSlash        = TokenKind("/")
+	TokenDot          = TokenKind(".") // A '.' immediately following a name, array index, or ']'
+	TokenLeftBracket  = TokenKind("[")
+	TokenRightBracket = TokenKind("]")
+	TokenPointer      = TokenKind("Pointer") // A JSON Pointer (RFC 6901): /a~1b/c
+	TokenColon        = TokenKind(":")
+	TokenSymbol       = TokenKind("Symbol")
+	TokenNumber       = TokenKind("Number")
+	TokenDate         = TokenKind("Date")
+	TokenTimeOfDay    = TokenKind("TimeOfDay")
+	TokenGUID         = TokenKind("GUID")
+	TokenString       = TokenKind("String")      // 'text'
+	TokenTypedString  = TokenKind("TypedString") // A type name prefixing a string: time'2020-01-01T00:00:00Z'
+	TokenQuotedName   = TokenKind("QuotedName")  // A name in double quotes: "odd.key"
 )
 
+// NumberKind classifies a Number token.
+type NumberKind string
+
+const (
+	NumberInteger = NumberKind("Integer") // 42, 42L
+	NumberDecimal = NumberKind("Decimal") // 3.14, 3.14m, 42m
+	NumberDouble  = NumberKind("Double")  // 1e6, 2.5E-3, 3.14d, INF, -INF, NaN
+)
+
+// Position is a location in a filter's text.
+type Position struct {
+	Offset int // Byte offset, starting at 0
+	Line   int // Line number, starting at 1
+	Column int // Column number in runes (not bytes), starting at 1
+}
+
+func (p Position) String() string { return fmt.Sprintf("%d:%d", p.Line, p.Column) }
+
 // Token represents a lexical token.
 type Token struct {
-	TokenKind        // The kind of token
-	Symbol    string // The value of the token
-	Error     error  // The error, if any
+	TokenKind            // The kind of token
+	Symbol    string     // The value of the token
+	Value     string     // For a String, TypedString, or QuotedName, the unescaped text between the quotes; for a Number, the number without its suffix
+	Number    NumberKind // For a Number, how its value should be represented
+	Position  Position   // Where the token starts; for an Error, where the error was found
+	Trivia    []Token    // The whitespace and comments preceding the token
+	Error     error      // The error, if any
 }
 
 // lexer scans a string finding its tokens
@@ -37,33 +74,60 @@ type lexer struct {
 	pos    int     // current position in the input string
 	width  int     // width of last rune read from input
 	start  int     // start position of the current token
+	line   int     // line number of the current token's start
+	column int     // column number, in runes, of the current token's start
 	tokens []Token // tokens extracted from the input
+	trivia []Token // whitespace and comments to attach to the next token
+}
+
+// isSymbolStart returns true if r can start a symbol: a letter or an underscore
+func isSymbolStart(r rune) bool { return unicode.IsLetter(r) || r == '_' }
+
+// isSymbolChar returns true if r can continue a symbol: a letter, digit, underscore, or dash
+func isSymbolChar(r rune) bool { return isSymbolStart(r) || unicode.IsDigit(r) || r == '-' }
+
+// isPointerChar returns true if r can appear in a JSON Pointer
+func isPointerChar(r rune) bool {
+	return r != eof && !unicode.IsSpace(r) && !strings.ContainsRune("(),", r)
+}
+
+// IsSymbol returns true if s scans as a single symbol; a name that isn't must be quoted: "odd.key"
+func IsSymbol(s string) bool {
+	tokens := GetTokens(s)
+	return len(tokens) == 2 && tokens[0].TokenKind == TokenSymbol && tokens[0].Symbol == s
 }
 
+// GetTokens returns the tokens in s; the last token is EOF. Each invalid character or
+// unterminated string is returned as an Error token and scanning continues after it.
+// If there are no Error tokens, concatenating each token's Trivia and Symbol reproduces s.
 func GetTokens(s string) []Token {
-	const (
-		whitespace   = " \t"
-		upperLetters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
-		lowerLetters = "abcdefghijklmnopqrstuvwxyz"
-		letters      = upperLetters + lowerLetters
-		digits       = "0123456789"
-		alphanumeric = letters + digits
-		literalChar  = "'"
-		timeChars    = literalChar + "-:."
-		symbolChars  = alphanumeric + timeChars
-	)
-
-	l := &lexer{input: s}
+	l := &lexer{input: s, line: 1, column: 1}
 	for {
 		switch r := l.next(); { // Consume next rune
 		case r == eof:
 			l.emit(TokenEOF)
 			return l.tokens
 
-		case strings.ContainsRune(whitespace, r): // Skip any whitespace
-			l.acceptRun(whitespace)
+		case unicode.IsSpace(r): // Any Unicode whitespace
+			for unicode.IsSpace(l.next()) {
+			}
+			l.backup()
 			l.emit(TokenWhitespace)
 
+		case (r == '/' || r == '-') && l.acceptOne(string(r)): // Line comment: // or --
+			l.pos += strings.IndexByte(l.input[l.pos:]+"\n", '\n')
+			l.emit(TokenComment)
+
+		case r == '/' && l.acceptOne("*"): // Block comment
+			end := strings.Index(l.input[l.pos:], "*/")
+			if end < 0 {
+				l.pos = len(l.input)
+				l.errorf("Unterminated comment: %s", l.input[l.start:])
+				break
+			}
+			l.pos += end + len("*/")
+			l.emit(TokenComment)
+
 		case r == '(':
 			l.emit(TokenLeftParen)
 
@@ -73,22 +137,70 @@ func GetTokens(s string) []Token {
 		case r == ',':
 			l.emit(TokenComma)
 
-		case strings.ContainsRune("+-"+digits, r): // Number/Float if starts with +, -, or a digit
-			l.acceptOne("+-")
-			l.acceptRun(digits)
-			if l.acceptOne(".") { // Decimal point?
-				l.acceptRun(digits)
+		case r == '/' && !l.follows() && isPointerChar(l.peek()): // A JSON Pointer doesn't follow another token
+			for r := l.next(); isPointerChar(r); r = l.next() {
+			}
+			l.backup()
+			l.emit(TokenPointer)
+
+		case r == '/':
+			l.emit(TokenSlash)
+
+		case r == '.' && l.follows(TokenSymbol, TokenQuotedName, TokenNumber, TokenRightBracket):
+			l.emit(TokenDot)
+
+		case r == '[':
+			l.emit(TokenLeftBracket)
+
+		case r == ']':
+			l.emit(TokenRightBracket)
+
+		case l.follows(TokenSlash, TokenDot, TokenLeftBracket) && l.acceptRegexp(indexRegexp): // An array index: items/0, items[-1]
+			l.emitToken(Token{TokenKind: TokenNumber, Value: l.input[l.start:l.pos], Number: NumberInteger})
+
+		case r == ':':
+			l.emit(TokenColon)
+
+		case l.acceptRegexp(guidRegexp): // GUID if xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+			l.emit(TokenGUID)
+
+		case l.acceptRegexp(dateRegexp): // Date if yyyy-mm-dd
+			l.emit(TokenDate)
+
+		case l.acceptRegexp(timeOfDayRegexp): // Time of day if hh:mm[:ss[.fraction]]
+			l.emit(TokenTimeOfDay)
+
+		case l.acceptRegexp(specialNumberRegexp): // INF, -INF, or NaN
+			l.emitToken(Token{TokenKind: TokenNumber, Value: l.input[l.start:l.pos], Number: NumberDouble})
+
+		case l.acceptRegexp(numberRegexp): // Number if [+-] digits [. digits] [exponent] or [+-] . digits [exponent]
+			number := l.input[l.start:l.pos]
+			l.emitToken(Token{TokenKind: TokenNumber, Value: number, Number: l.acceptNumberSuffix()})
+
+		case r == '\'': // String
+			l.emitString(TokenString)
+
+		case r == '"': // Quoted name
+			l.emitString(TokenQuotedName)
+
+		case isSymbolStart(r): // Symbol if starts with a letter or underscore
+			for isSymbolChar(l.next()) {
+			}
+			l.backup()
+			if i := strings.Index(l.input[l.start:l.pos], "--"); i >= 0 { // A comment follows the symbol
+				l.pos = l.start + i
 			}
-			l.emit(TokenNumber)
+			if !l.acceptOne("'") {
+				l.emit(TokenSymbol)
+				break
+			}
+			l.emitString(TokenTypedString) // A symbol immediately followed by a string prefixes it with a type name
 
-		case strings.ContainsRune(alphanumeric+"'", r): // Symbol if starts with a letter
-			l.acceptRun(symbolChars)
-			l.emit(TokenSymbol)
+		case !unicode.IsPrint(r): // Quote a control character such as NUL so the message shows it
+			l.errorf("Invalid character: %q", r)
 
 		default:
-			l.tokens = append(l.tokens,
-				Token{TokenKind: TokenError, Symbol: fmt.Sprintf("Invalid character: %s", l.input[l.start:])})
-			return l.tokens
+			l.errorf("Invalid character: %s", string(r))
 		}
 	}
 }
@@ -107,6 +219,31 @@ func (l *lexer) next() (rune rune) {
 // backup places the previously read rune back
 func (l *lexer) backup() { l.pos -= l.width }
 
+// peek returns the next rune without consuming it
+func (l *lexer) peek() rune {
+	r := l.next()
+	l.backup()
+	return r
+}
+
+// follows returns true if the current token immediately follows (without any trivia) a token of one of
+// kinds; with no kinds, it returns true if the current token immediately follows any name, literal, or ')'.
+func (l *lexer) follows(kinds ...TokenKind) bool {
+	if len(l.tokens) == 0 || len(l.trivia) > 0 {
+		return false
+	}
+	prev := l.tokens[len(l.tokens)-1].TokenKind
+	if len(kinds) == 0 {
+		return prev != TokenLeftParen && prev != TokenComma && prev != TokenColon && prev != TokenError
+	}
+	for _, k := range kinds {
+		if prev == k {
+			return true
+		}
+	}
+	return false
+}
+
 func (l *lexer) acceptOne(validChars string) bool {
 	if strings.ContainsRune(validChars, l.next()) {
 		return true
@@ -121,14 +258,133 @@ func (l *lexer) acceptRun(valid string) {
 	l.backup()
 }
 
-func (l *lexer) emit(tk TokenKind) {
-	t := Token{TokenKind: tk, Symbol: l.input[l.start:l.pos]}
+// dateRegexp matches a date: yyyy-mm-dd
+var dateRegexp = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}`)
+
+// guidRegexp matches a GUID: 01234567-89ab-cdef-0123-456789abcdef
+var guidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+
+// timeOfDayRegexp matches a time of day: 13:45, 13:45:00, 13:45:00.5
+var timeOfDayRegexp = regexp.MustCompile(`^[0-9]{2}:[0-9]{2}(:[0-9]{2}(\.[0-9]+)?)?`)
+
+// numberRegexp matches a number without its type suffix: -12, 3.14, .5, 1e6, 2.5E-3
+var numberRegexp = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?`)
+
+// indexRegexp matches an array index in a property path
+var indexRegexp = regexp.MustCompile(`^-?[0-9]+`)
+
+// specialNumberRegexp matches the doubles that aren't finite numbers
+var specialNumberRegexp = regexp.MustCompile(`^(-?INF|NaN)\b`)
+
+// acceptNumberSuffix classifies the number just accepted by numberRegexp and accepts its optional
+// type suffix: l or L (integer), m or M (decimal), d, D, f, or F (double). A letter immediately
+// followed by another symbol character isn't a suffix: 5days
+func (l *lexer) acceptNumberSuffix() NumberKind {
+	number, end := l.input[l.start:l.pos], l.pos
+	kind, suffixes := NumberInteger, "lLmMdDfF"
+	switch {
+	case strings.ContainsAny(number, "eE"):
+		kind, suffixes = NumberDouble, "dDfF"
+	case strings.Contains(number, "."):
+		kind, suffixes = NumberDecimal, "mMdDfF"
+	}
+	if !l.acceptOne(suffixes) {
+		return kind
+	}
+	if isSymbolChar(l.peek()) {
+		l.pos = end
+		return kind
+	}
+	switch l.input[end] {
+	case 'm', 'M':
+		return NumberDecimal
+	case 'd', 'D', 'f', 'F':
+		return NumberDouble
+	}
+	return NumberInteger
+}
+
+// acceptRegexp accepts the input matching re starting at the current token's start
+func (l *lexer) acceptRegexp(re *regexp.Regexp) bool {
+	match := re.FindString(l.input[l.start:])
+	if match == "" {
+		return false
+	}
+	l.pos = l.start + len(match)
+	return true
+}
+
+// acceptString accepts the rest of a string whose opening quote has been read. A string may contain
+// any character; two consecutive quote characters represent a single quote character. value is the
+// string with its quotes removed and unescaped; ok is false if the input ends before the closing quote.
+func (l *lexer) acceptString(quote rune) (value string, ok bool) {
+	var sb strings.Builder
+	for {
+		switch r := l.next(); {
+		case r == eof:
+			return "", false
+		case r == quote && !l.acceptOne(string(quote)):
+			return sb.String(), true
+		default:
+			sb.WriteRune(r)
+		}
+	}
+}
+
+// emitString accepts the rest of a string whose opening quote has been read and emits it as a tk token;
+// an unterminated string extends to the end of the input and is emitted as an error token.
+func (l *lexer) emitString(tk TokenKind) {
+	quote, what := '\'', "string"
+	if tk == TokenQuotedName {
+		quote, what = '"', "quoted name"
+	}
+	value, ok := l.acceptString(quote)
+	if !ok {
+		l.errorf("Unterminated %s: %s", what, l.input[l.start:])
+		return
+	}
+	l.emitToken(Token{TokenKind: tk, Value: value})
+}
+
+// errorf emits an error token in place of the current token; the token's Symbol describes the error
+func (l *lexer) errorf(format string, args ...any) {
+	l.tokens = append(l.tokens, Token{TokenKind: TokenError, Symbol: fmt.Sprintf(format, args...), Position: l.position(), Trivia: l.trivia})
+	l.trivia = nil
+	l.skip()
+}
+
+// position returns the position of the current token's start
+func (l *lexer) position() Position {
+	return Position{Offset: l.start, Line: l.line, Column: l.column}
+}
+
+func (l *lexer) emit(tk TokenKind) { l.emitToken(Token{TokenKind: tk}) }
+
+// emitToken emits t after setting its Symbol and Position from the current token
+func (l *lexer) emitToken(t Token) {
+	t.Symbol, t.Position = l.input[l.start:l.pos], l.position()
 	showToken(t)
-	if tk != TokenWhitespace { // If whitespace, do NOT emit this token
+	if t.TokenKind == TokenWhitespace || t.TokenKind == TokenComment { // Attach trivia to the next token
+		l.trivia = append(l.trivia, t)
+	} else {
+		t.Trivia, l.trivia = l.trivia, nil
 		l.tokens = append(l.tokens, t)
 	}
+	l.skip()
+}
+
+// skip starts the next token at the current position, advancing the line and column past the current token
+// (whitespace and strings may span lines) so that finding a position doesn't rescan the line.
+func (l *lexer) skip() {
+	for _, r := range l.input[l.start:l.pos] {
+		if r == '\n' {
+			l.line, l.column = l.line+1, 1
+		} else {
+			l.column++
+		}
+	}
 	l.start = l.pos
 }
 
 // eof represents a marker rune for the end of the reader.
-const eof = rune(0)
+const eof = rune(-1) // Not a valid rune so that a NUL character in the input isn't mistaken for it
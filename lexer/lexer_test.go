@@ -0,0 +1,129 @@
+package lexer
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestGetTokensStrings(t *testing.T) {
+	for _, tt := range []struct {
+		input, value string
+	}{
+		{`'Jeff'`, "Jeff"},
+		{`'São Paulo'`, "São Paulo"},
+		{`'O''Brien'`, "O'Brien"},
+		{`''''`, "'"},
+		{`''`, ""},
+		{`'日本 🎉'`, "日本 🎉"},
+	} {
+		tokens := GetTokens(tt.input)
+		if len(tokens) != 2 || tokens[0].TokenKind != TokenString || tokens[0].Value != tt.value || tokens[0].Symbol != tt.input {
+			t.Errorf("GetTokens(%q) = %+v, want a String whose value is %q", tt.input, tokens, tt.value)
+		}
+	}
+	if tokens := GetTokens(`'abc`); tokens[0].TokenKind != TokenError {
+		t.Errorf("GetTokens('abc) = %+v, want an Error for the unterminated string", tokens)
+	}
+}
+
+func TestGetTokensPositions(t *testing.T) {
+	long := strings.Repeat("a eq 'é' and ", 1000) + "b eq 1"
+	tokens := GetTokens("x eq 'São\nPaulo' and\r\n\ty eq 1 and " + long)
+	for _, tt := range []struct {
+		i            int
+		symbol       string
+		line, column int
+	}{
+		{0, "x", 1, 1},
+		{2, "'São\nPaulo'", 1, 6},
+		{3, "and", 2, 8},
+		{4, "y", 3, 2},
+		{7, "and", 3, 9},
+		{8, "a", 3, 13},
+		{len(tokens) - 2, "1", 3, 12 + utf8.RuneCountInString(long)},
+	} {
+		tk := tokens[tt.i]
+		if tk.Symbol != tt.symbol || tk.Position.Line != tt.line || tk.Position.Column != tt.column {
+			t.Errorf("token %d = %q at %v, want %q at %d:%d", tt.i, tk.Symbol, tk.Position, tt.symbol, tt.line, tt.column)
+		}
+	}
+}
+
+func TestGetTokensNumbers(t *testing.T) {
+	for _, tt := range []struct {
+		input, value string
+		kind         NumberKind
+	}{
+		{"42", "42", NumberInteger},
+		{"-42L", "-42", NumberInteger},
+		{"+7l", "+7", NumberInteger},
+		{"3.14", "3.14", NumberDecimal},
+		{".5", ".5", NumberDecimal},
+		{"42m", "42", NumberDecimal},
+		{"3.14M", "3.14", NumberDecimal},
+		{"1e6", "1e6", NumberDouble},
+		{"2.5E-3", "2.5E-3", NumberDouble},
+		{"3.14d", "3.14", NumberDouble},
+		{"42F", "42", NumberDouble},
+		{"INF", "INF", NumberDouble},
+		{"-INF", "-INF", NumberDouble},
+		{"NaN", "NaN", NumberDouble},
+	} {
+		tokens := GetTokens(tt.input)
+		if len(tokens) != 2 || tokens[0].TokenKind != TokenNumber || tokens[0].Value != tt.value || tokens[0].Number != tt.kind {
+			t.Errorf("GetTokens(%q) = %+v, want a %s Number whose value is %q", tt.input, tokens, tt.kind, tt.value)
+		}
+	}
+	if tokens := GetTokens("5days"); tokens[0].Symbol != "5" || tokens[1].Symbol != "days" {
+		t.Errorf("GetTokens(5days) = %+v, want 5 followed by days", tokens)
+	}
+}
+
+func TestGetTokensTrivia(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		want  []string // Each token's trivia and symbol
+	}{
+		{"a\r\n\teq\u00a01", []string{"a", "\r\n\t|eq", "\u00a0|1", ""}},
+		{"a eq 1 // trailing", []string{"a", " |eq", " |1", " |// trailing|"}},
+		{"-- first\na -- second\n", []string{"-- first|\n|a", " |-- second|\n|"}},
+		{"a/* one */eq /* two\nlines */1", []string{"a", "/* one */|eq", " |/* two\nlines */|1", ""}},
+		{"first_name--x", []string{"first_name", "--x|"}},
+		{"a eq -1", []string{"a", " |eq", " |-1", ""}},
+	} {
+		var got []string
+		for _, tk := range GetTokens(tt.input) {
+			var parts []string
+			for _, trivia := range tk.Trivia {
+				parts = append(parts, trivia.Symbol)
+			}
+			got = append(got, strings.Join(append(parts, tk.Symbol), "|"))
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("GetTokens(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+	if tokens := GetTokens("a /* b"); tokens[len(tokens)-2].TokenKind != TokenError {
+		t.Errorf("GetTokens(a /* b) = %+v, want an Error for the unterminated comment", tokens)
+	}
+}
+
+func TestGetTokensNUL(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		want  []string // Each token's kind and symbol
+	}{
+		{"a\x00", []string{"Symbol a", "Error Invalid character: '\\x00'", "EOF "}},
+		{"a eq '\x00'", []string{"Symbol a", "Symbol eq", "String '\x00'", "EOF "}},
+		{"/a\x00b eq 1", []string{"Pointer /a\x00b", "Symbol eq", "Number 1", "EOF "}},
+	} {
+		var got []string
+		for _, tk := range GetTokens(tt.input) {
+			got = append(got, string(tk.TokenKind)+" "+tk.Symbol)
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("GetTokens(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -37,7 +37,8 @@ func main() {
 		"int gt 23 and " + // false
 		"float le 5 or " + // true
 		"contains(string, 'eef')" // false
-	b := true || true && true && (true && false) && false && true || false
+	nullFoo, childInt, timeGt, boolAndString, intGt, floatLe, containsEef := true, true, true, false, false, true, false
+	b := nullFoo || childInt && timeGt && boolAndString && intGt && floatLe || containsEef
 
 	//test = "contains(string, 'ff')"
 	f, err := filter.New(test)
@@ -0,0 +1,282 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"githib.com/JeffreyRichter/filter/lexer"
+)
+
+// ArithmeticOp represents an arithmetic operator and provides some type safety.
+type ArithmeticOp string
+
+const (
+	ArithmeticAdd   = ArithmeticOp("add")
+	ArithmeticSub   = ArithmeticOp("sub")
+	ArithmeticMul   = ArithmeticOp("mul")
+	ArithmeticDiv   = ArithmeticOp("div")   // Integer division if both operands are integers
+	ArithmeticDivBy = ArithmeticOp("divby") // Always floating-point division
+	ArithmeticMod   = ArithmeticOp("mod")
+)
+
+// isAdditive returns true if op is add or sub; these have lower precedence than the others
+func (op ArithmeticOp) isAdditive() bool { return op == ArithmeticAdd || op == ArithmeticSub }
+
+// Arithmetic is a binary arithmetic operation such as: price mul quantity
+type Arithmetic struct {
+	Op          ArithmeticOp
+	Left, Right Node
+}
+
+func (a *Arithmetic) Pos() lexer.Position { return a.Left.Pos() }
+
+func (a *Arithmetic) String() string {
+	// Operators are left-associative so a right operand of equal precedence needs parentheses
+	return fmt.Sprintf("%s %s %s", parenthesize(a.Left, precedence(a)), a.Op, parenthesize(a.Right, precedence(a)+1))
+}
+
+// Evaluate applies the operator to the left and right values. If either value is nil
+// (a property that doesn't exist), the result is nil.
+func (a *Arithmetic) Evaluate(left, right any) (any, error) {
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	if isTemporal(left) || isTemporal(right) {
+		return a.evaluateTemporal(left, right)
+	}
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("Type mismatch: %s='%v' %s %s='%v' requires numbers", a.Left, left, a.Op, a.Right, right)
+	}
+	if isDecimal(l) || isDecimal(r) {
+		return a.evaluateDecimal(l, r)
+	}
+	if isBig(l) || isBig(r) {
+		return a.evaluateBig(l, r)
+	}
+	li, lInt := l.(int64)
+	ri, rInt := r.(int64)
+	if lInt && rInt && a.Op != ArithmeticDivBy {
+		return a.evaluateInteger(li, ri)
+	}
+	return a.evaluateFloat(toFloat(l), toFloat(r))
+}
+
+func (a *Arithmetic) evaluateInteger(l, r int64) (any, error) {
+	var v int64
+	overflow := false
+	switch a.Op {
+	case ArithmeticAdd:
+		v = l + r
+		overflow = (l^v)&(r^v) < 0 // The result's sign differs from both operands' signs
+	case ArithmeticSub:
+		v = l - r
+		overflow = (l^r)&(l^v) < 0 // The operands' signs differ and the result's sign differs from l's
+	case ArithmeticMul:
+		v = l * r
+		overflow = l != 0 && (v/l != r || (l == -1 && r == math.MinInt64))
+	case ArithmeticDiv, ArithmeticMod:
+		if r == 0 {
+			return nil, fmt.Errorf("Division by zero: %s", a)
+		}
+		if a.Op == ArithmeticMod {
+			return l % r, nil
+		}
+		v = l / r
+		overflow = l == math.MinInt64 && r == -1
+	}
+	if overflow {
+		return nil, fmt.Errorf("Integer overflow: %s", a)
+	}
+	return v, nil
+}
+
+func (a *Arithmetic) evaluateFloat(l, r float64) (any, error) {
+	var v float64
+	switch a.Op {
+	case ArithmeticAdd:
+		v = l + r
+	case ArithmeticSub:
+		v = l - r
+	case ArithmeticMul:
+		v = l * r
+	case ArithmeticDiv, ArithmeticDivBy, ArithmeticMod:
+		if r == 0 {
+			return nil, fmt.Errorf("Division by zero: %s", a)
+		}
+		if a.Op == ArithmeticMod {
+			v = math.Mod(l, r)
+		} else {
+			v = l / r
+		}
+	}
+	if math.IsInf(v, 0) && !math.IsInf(l, 0) && !math.IsInf(r, 0) {
+		return nil, fmt.Errorf("Number overflow: %s", a)
+	}
+	return v, nil
+}
+
+// evaluateBig applies the operator when either operand is a *big.Int or *big.Float. Integers produce an
+// exact integer (an int64 if it fits) unless the operator is divby; otherwise the result is a *big.Float.
+// An operand that is infinite or NaN produces a float64 as evaluateFloat does.
+func (a *Arithmetic) evaluateBig(l, r any) (any, error) {
+	li, lInt := toBigInt(l)
+	ri, rInt := toBigInt(r)
+	divides := a.Op == ArithmeticDiv || a.Op == ArithmeticDivBy || a.Op == ArithmeticMod
+	if lInt && rInt && a.Op != ArithmeticDivBy {
+		if divides && ri.Sign() == 0 {
+			return nil, fmt.Errorf("Division by zero: %s", a)
+		}
+		v := new(big.Int)
+		switch a.Op {
+		case ArithmeticAdd:
+			v.Add(li, ri)
+		case ArithmeticSub:
+			v.Sub(li, ri)
+		case ArithmeticMul:
+			v.Mul(li, ri)
+		case ArithmeticDiv:
+			v.Quo(li, ri) // Truncates like int64 division
+		case ArithmeticMod:
+			v.Rem(li, ri) // Has the sign of l like int64 mod
+		}
+		n, _ := toNumber(v)
+		return n, nil
+	}
+	for _, n := range []any{l, r} {
+		if f, ok := n.(float64); ok && (math.IsInf(f, 0) || math.IsNaN(f)) {
+			lf, _ := nearestFloat(l)
+			rf, _ := nearestFloat(r)
+			return a.evaluateFloat(lf, rf)
+		}
+	}
+	lf, rf := toBigFloat(l), toBigFloat(r)
+	if divides && rf.Sign() == 0 {
+		return nil, fmt.Errorf("Division by zero: %s", a)
+	}
+	v := new(big.Float)
+	switch a.Op {
+	case ArithmeticAdd:
+		v.Add(lf, rf)
+	case ArithmeticSub:
+		v.Sub(lf, rf)
+	case ArithmeticMul:
+		v.Mul(lf, rf)
+	case ArithmeticDiv, ArithmeticDivBy:
+		v.Quo(lf, rf)
+	case ArithmeticMod: // l - r*trunc(l/r), which has the sign of l like math.Mod
+		q, _ := v.Quo(lf, rf).Int(nil)
+		v.Sub(lf, v.Mul(rf, new(big.Float).SetInt(q)))
+	}
+	return v, nil
+}
+
+// evaluateDecimal applies the operator when either operand is a Decimal. With an integer or another decimal,
+// the result is an exact Decimal (a quotient that isn't a decimal, like 1 div 3, is rounded; see Decimal.quo);
+// with a float, the decimal is converted to a float and the result is a float.
+func (a *Arithmetic) evaluateDecimal(l, r any) (any, error) {
+	for _, n := range []any{l, r} {
+		switch n.(type) {
+		case float64:
+			lf, _ := nearestFloat(l)
+			rf, _ := nearestFloat(r)
+			return a.evaluateFloat(lf, rf)
+		case *big.Float:
+			return a.evaluateBig(toBigFloat(l), toBigFloat(r))
+		}
+	}
+	ld, rd := decimalOf(l), decimalOf(r)
+	switch a.Op {
+	case ArithmeticAdd:
+		return ld.add(rd), nil
+	case ArithmeticSub:
+		return ld.sub(rd), nil
+	case ArithmeticMul:
+		return ld.mul(rd), nil
+	}
+	if rd.coeff().Sign() == 0 {
+		return nil, fmt.Errorf("Division by zero: %s", a)
+	}
+	if a.Op == ArithmeticMod {
+		return ld.rem(rd), nil
+	}
+	return ld.quo(rd), nil // div and divby
+}
+
+// isTemporal returns true if v is a time or a duration.
+func isTemporal(v any) bool {
+	switch v.(type) {
+	case time.Time, time.Duration:
+		return true
+	}
+	return false
+}
+
+// evaluateTemporal adds or subtracts times and durations: time add/sub duration is a time,
+// duration add time is a time, time sub time is a duration, and duration add/sub duration is a duration.
+func (a *Arithmetic) evaluateTemporal(left, right any) (any, error) {
+	switch l := left.(type) {
+	case time.Time:
+		switch r := right.(type) {
+		case time.Duration:
+			if a.Op == ArithmeticAdd {
+				return l.Add(r), nil
+			}
+			if a.Op == ArithmeticSub {
+				return l.Add(-r), nil
+			}
+		case time.Time:
+			if a.Op == ArithmeticSub {
+				return l.Sub(r), nil
+			}
+		}
+	case time.Duration:
+		switch r := right.(type) {
+		case time.Duration:
+			if a.Op.isAdditive() {
+				v, err := a.evaluateInteger(int64(l), int64(r))
+				if err != nil {
+					return nil, fmt.Errorf("Duration overflow: %s", a)
+				}
+				return time.Duration(v.(int64)), nil
+			}
+		case time.Time:
+			if a.Op == ArithmeticAdd {
+				return r.Add(l), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("Type mismatch: %s='%v' %s %s='%v' requires numbers, or times and durations", a.Left, left, a.Op, a.Right, right)
+}
+
+// resultKind returns the kind of value op produces from operands of kinds l and r where "" means the kind
+// is unknown; ok is false if op can't be applied to operands of these kinds. See evaluateTemporal.
+func (op ArithmeticOp) resultKind(l, r Kind) (k Kind, ok bool) {
+	if l == KindNull { // A null operand produces null whatever the other operand is
+		l = ""
+	}
+	if r == KindNull {
+		r = ""
+	}
+	temporal := func(k Kind) bool { return k == "" || k == KindTime || k == KindDuration }
+	switch {
+	case l == "" && r == "":
+		return "", true
+	case (l == "" || l == KindNumber) && (r == "" || r == KindNumber):
+		return KindNumber, true
+	case !op.isAdditive() || !temporal(l) || !temporal(r):
+		return "", false
+	case l == "" || r == "":
+		return "", true
+	case l == KindDuration && r == KindDuration:
+		return KindDuration, true
+	case l == KindTime && r == KindDuration, l == KindDuration && r == KindTime && op == ArithmeticAdd:
+		return KindTime, true
+	case l == KindTime && r == KindTime && op == ArithmeticSub:
+		return KindDuration, true
+	}
+	return "", false
+}
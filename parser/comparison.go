@@ -1,11 +1,9 @@
 package parser
 
 import (
-	"errors"
 	"fmt"
-	"reflect"
-	"strconv"
-	"strings"
+	"math"
+	"math/big"
 	"time"
 
 	"githib.com/JeffreyRichter/filter/lexer"
@@ -14,189 +12,262 @@ import (
 // CompareOp represents a comparison operator and provides some type safety.
 type CompareOp string
 
+const (
+	CompareEq = CompareOp("eq")
+	CompareNe = CompareOp("ne")
+	CompareGt = CompareOp("gt")
+	CompareGe = CompareOp("ge")
+	CompareLt = CompareOp("lt")
+	CompareLe = CompareOp("le")
+)
+
+// Reverse returns the operator that produces the same result when the operands are swapped: lt becomes gt.
+func (op CompareOp) Reverse() CompareOp {
+	switch op {
+	case CompareGt:
+		return CompareLt
+	case CompareGe:
+		return CompareLe
+	case CompareLt:
+		return CompareGt
+	case CompareLe:
+		return CompareGe
+	}
+	return op // eq and ne are symmetric
+}
+
+// isCompareOp returns true if s is one of the comparison operators.
+func isCompareOp(s string) bool {
+	switch CompareOp(s) {
+	case CompareEq, CompareNe, CompareGt, CompareGe, CompareLt, CompareLe:
+		return true
+	}
+	return false
+}
+
+// Comparison compares the value of an expression (Left) against the value of another expression (Right).
+// The parser puts a constant expression, such as a literal, on the Right unless both sides are constant.
 type Comparison struct {
-	PropName string // Must be an lexer.Ident
+	Left     Node
 	Op       CompareOp
-	Literal  lexer.Token
+	Right    Node
+	Position lexer.Position
 }
 
-func (c Comparison) Evaluate(jsonVal any) (b bool, err error) {
-	if c.Literal.Symbol == "null" { // Comparisons to null are a special case
-		b, err = c.compareNullProp(jsonVal != nil)
-	} else if err != nil {
-		return false, err // Property doesn't exist, report the error
-	} else {
-		switch jv := jsonVal.(type) {
-		case bool:
-			b, err = c.compareBooleanProp(jv)
+func (c *Comparison) Pos() lexer.Position { return c.Position }
 
-		case int, int8, int16, int32, int64:
-			b, err = c.compareIntegerProp(reflect.ValueOf(jsonVal).Int())
+func (c *Comparison) String() string {
+	return fmt.Sprintf("%s %s %s", parenthesize(c.Left, precedence(c)+1), c.Op, parenthesize(c.Right, precedence(c)+1))
+}
 
-		case float32, float64:
-			b, err = c.compareFloatProp(reflect.ValueOf(jsonVal).Float())
+type typeMismatchError struct {
+	msg string
+}
 
-		case string:
-			b, err = c.compareStringProp(jv)
+func (e *typeMismatchError) SetMsg(c *Comparison, left, right any) error {
+	return typeMismatchError{msg: fmt.Sprintf("Type mismatch: %s='%v' while %s='%v'", c.Left, left, c.Right, right)}
+}
 
-		case time.Time:
-			b, err = c.compareTimeProp(jv)
-		}
-		if err != nil {
-			if tme, ok := err.(typeMismatchError); ok {
-				err = tme.SetMsg(c, jsonVal, c.Literal)
-			}
-			return false, err
+func (e typeMismatchError) Error() string { return e.msg }
+
+// Evaluate compares the left value against the right value; either is nil if it is null or a property
+// that doesn't exist. Numbers of any Go numeric type (see toNumber) may be compared with each other exactly.
+func (c *Comparison) Evaluate(left, right any) (b bool, err error) {
+	if left == nil || right == nil { // Comparisons to null (or a property that doesn't exist) are a special case
+		return c.compareNull(left == nil, right == nil)
+	}
+	if n, ok := toNumber(right); ok { // The compare methods expect a number returned from toNumber
+		right = n
+	}
+	if n, ok := toNumber(left); ok {
+		left = n
+	}
+	switch l := left.(type) {
+	case bool:
+		b, err = c.compareBoolean(l, right)
+
+	case int64, float64, *big.Int, *big.Float, Decimal:
+		b, err = c.compareNumber(l, right)
+
+	case string:
+		b, err = c.compareString(l, right)
+
+	case time.Time:
+		b, err = c.compareTime(l, right)
+
+	case Date:
+		b, err = c.compareDate(l, right)
+
+	case TimeOfDay:
+		b, err = c.compareTimeOfDay(l, right)
+
+	case time.Duration:
+		b, err = c.compareDuration(l, right)
+
+	case GUID, [16]byte:
+		b, err = c.compareGUID(left, right)
+
+	case []byte:
+		b, err = c.compareBinary(l, right)
+
+	default:
+		return false, fmt.Errorf("Unsupported value type %T: %s='%v'", left, c.Left, left)
+	}
+	if err != nil {
+		if tme, ok := err.(typeMismatchError); ok {
+			err = tme.SetMsg(c, left, right)
 		}
+		return false, err
 	}
 	return b, nil
 }
 
-func (c Comparison) compareNullProp(propertyExists bool) (bool, error) {
-	if c.Literal.TokenKind != lexer.TokenSymbol || c.Literal.Symbol != "null" {
-		panic("Caller shouldn't have called us")
-	}
+func (c *Comparison) invalidOp() error { return fmt.Errorf("Invalid operator: '%s'", c.Op) }
+
+// compareNull compares values when either is null: eq is true if both are null and ne is true if only one is.
+func (c *Comparison) compareNull(leftNull, rightNull bool) (bool, error) {
 	switch c.Op {
-	case "eq":
-		return !propertyExists, nil // true if property doesn't exist
-	case "ne":
-		return propertyExists, nil // true if property exists
+	case CompareEq:
+		return leftNull == rightNull, nil
+	case CompareNe:
+		return leftNull != rightNull, nil
 	default:
-		return false, errors.New(fmt.Sprintf("Invalid operator: '%s'", c.Op))
+		return false, nil // Nothing is ordered relative to null
 	}
 }
 
-func (c Comparison) compareBooleanProp(v bool) (bool, error) {
-	if c.Literal.TokenKind != lexer.TokenSymbol || c.Literal.Symbol != "true" && c.Literal.Symbol != "false" {
+func (c *Comparison) compareBoolean(v bool, right any) (bool, error) {
+	n, ok := right.(bool)
+	if !ok {
 		return false, typeMismatchError{}
 	}
-	switch n := c.Literal.Symbol == "true"; c.Op {
-	case "eq":
+	switch c.Op {
+	case CompareEq:
 		return v == n, nil
-	case "ne":
+	case CompareNe:
 		return v != n, nil
 	}
-	return false, errors.New(fmt.Sprintf("Invalid operator: '%s'", c.Op))
+	return false, c.invalidOp()
 }
 
-func (c Comparison) compareIntegerProp(v int64) (bool, error) {
-	if c.Literal.TokenKind != lexer.TokenNumber {
+// compareNumber compares v and right, numbers returned from toNumber, exactly (see compareNumbers).
+// A Decimal also compares with a string in decimal form.
+func (c *Comparison) compareNumber(v, right any) (bool, error) {
+	switch n := right.(type) {
+	case int64, float64, *big.Int, *big.Float, Decimal:
+	case string:
+		d, err := ParseDecimal(n)
+		if !isDecimal(v) || err != nil {
+			return false, typeMismatchError{}
+		}
+		right = d
+	default:
 		return false, typeMismatchError{}
 	}
-	n, err := strconv.ParseInt(c.Literal.Symbol, 10, 64)
-	if err != nil {
-		var numerr *strconv.NumError
-		if !errors.As(err, &numerr) {
-			return false, err
-		}
-		switch numerr.Err {
-		case strconv.ErrRange:
-			return false, errors.New(fmt.Sprintf("Number out of range: '%s'", c.Literal.Symbol))
-		case strconv.ErrSyntax:
-			return false, errors.New(fmt.Sprintf("Number has improper syntax: '%s'", c.Literal.Symbol))
-		default:
-			return false, err
-		}
+	cmp, ok := compareNumbers(v, right)
+	if !ok { // NaN is unordered: only ne is true
+		return compareOrdered(c, math.NaN(), 0)
 	}
+	return compareOrdered(c, int64(cmp), 0)
+}
 
-	switch c.Op {
-	case "eq":
-		return v == n, nil
-	case "ne":
-		return v != n, nil
-	case "gt":
-		return v > n, nil
-	case "ge":
-		return v >= n, nil
-	case "lt":
-		return v < n, nil
-	case "le":
-		return v <= n, nil
+func (c *Comparison) compareString(v string, right any) (bool, error) {
+	switch n := right.(type) {
+	case string:
+		return compareOrdered(c, v, n)
+	case GUID, [16]byte: // The string must be in GUID form
+		return c.compareGUID(v, n)
+	case Decimal: // The string must be in decimal form
+		if d, err := ParseDecimal(v); err == nil {
+			return c.compareNumber(d, n)
+		}
 	}
-	return false, errors.New(fmt.Sprintf("Invalid operator: '%s'", c.Op))
+	return false, typeMismatchError{}
 }
 
-func (c Comparison) compareFloatProp(v float64) (bool, error) {
-	if c.Literal.TokenKind != lexer.TokenNumber {
+func (c *Comparison) compareTime(v time.Time, right any) (bool, error) {
+	n, ok := right.(time.Time)
+	if !ok {
 		return false, typeMismatchError{}
 	}
-	n, err := strconv.ParseFloat(c.Literal.Symbol, 64)
-	if err != nil {
-		return false, err
-	}
-
 	switch c.Op {
-	case "eq":
-		return v == n, nil
-	case "ne":
-		return v != n, nil
-	case "gt":
-		return v > n, nil
-	case "ge":
-		return v >= n, nil
-	case "lt":
-		return v < n, nil
-	case "le":
-		return v <= n, nil
+	case CompareEq:
+		return v.Equal(n), nil
+	case CompareNe:
+		return !v.Equal(n), nil
+	case CompareGt:
+		return v.After(n), nil
+	case CompareGe:
+		return v.Equal(n) || v.After(n), nil
+	case CompareLt:
+		return v.Before(n), nil
+	case CompareLe:
+		return v.Equal(n) || v.Before(n), nil
 	}
-	return false, errors.New(fmt.Sprintf("Invalid operator: '%s'", c.Op))
+	return false, c.invalidOp()
 }
 
-func isSymbolSurroundedBy(t lexer.Token, prefix, suffix string) (bool, string) {
-	b := t.TokenKind == lexer.TokenSymbol &&
-		strings.HasPrefix(t.Symbol, prefix) &&
-		strings.HasSuffix(t.Symbol, suffix)
-	if !b {
-		return false, ""
+func (c *Comparison) compareDate(v Date, right any) (bool, error) {
+	n, ok := right.(Date)
+	if !ok {
+		return false, typeMismatchError{}
 	}
-	return b, t.Symbol[len(prefix) : len(t.Symbol)-len(suffix)] // Remove prefix/suffix
+	return compareOrdered(c, v.ordinal(), n.ordinal())
 }
 
-func (c Comparison) compareStringProp(v string) (bool, error) {
-	ok, lit := isSymbolSurroundedBy(c.Literal, "'", "'")
+func (c *Comparison) compareTimeOfDay(v TimeOfDay, right any) (bool, error) {
+	n, ok := right.(TimeOfDay)
 	if !ok {
 		return false, typeMismatchError{}
 	}
-	n := strings.Compare(v, lit)
-	switch c.Op {
-	case "eq":
-		return n == 0, nil
-	case "ne":
-		return n != 0, nil
-	case "gt":
-		return n == 1, nil
-	case "ge":
-		return n >= 0, nil
-	case "lt":
-		return n == -1, nil
-	case "le":
-		return n <= 0, nil
-	}
-	return false, errors.New(fmt.Sprintf("Invalid operator: '%s'", c.Op))
-}
-
-func (c Comparison) compareTimeProp(v time.Time) (bool, error) {
-	ok, lit := isSymbolSurroundedBy(c.Literal, "time'", "'")
+	return compareOrdered(c, v, n)
+}
+
+func (c *Comparison) compareDuration(v time.Duration, right any) (bool, error) {
+	n, ok := right.(time.Duration)
 	if !ok {
 		return false, typeMismatchError{}
 	}
-	n, err := time.Parse(time.RFC3339, lit)
-	if err != nil {
-		return false, err
+	return compareOrdered(c, v, n)
+}
+
+// compareGUID compares left and right; each may be a GUID, a [16]byte, or a string in GUID form.
+func (c *Comparison) compareGUID(left, right any) (bool, error) {
+	v, lok := guidOf(left)
+	n, rok := guidOf(right)
+	if !lok || !rok {
+		return false, typeMismatchError{}
+	}
+	return compareOrdered(c, string(v[:]), string(n[:]))
+}
+
+func (c *Comparison) compareBinary(v []byte, right any) (bool, error) {
+	n, ok := right.([]byte)
+	if !ok {
+		return false, typeMismatchError{}
 	}
+	return compareOrdered(c, string(v), string(n))
+}
+
+// ordered is the set of types that support all the comparison operators.
+type ordered interface {
+	~int64 | ~float64 | ~string
+}
+
+func compareOrdered[T ordered](c *Comparison, v, n T) (bool, error) {
 	switch c.Op {
-	case "eq":
-		return v.Equal(n), nil
-	case "ne":
-		return !v.Equal(n), nil
-	case "gt":
-		return v.After(n), nil
-	case "ge":
-		return v.Equal(n) || v.After(n), nil
-	case "lt":
-		return v.Before(n), nil
-	case "le":
-		return v.Equal(n) || v.Before(n), nil
+	case CompareEq:
+		return v == n, nil
+	case CompareNe:
+		return v != n, nil
+	case CompareGt:
+		return v > n, nil
+	case CompareGe:
+		return v >= n, nil
+	case CompareLt:
+		return v < n, nil
+	case CompareLe:
+		return v <= n, nil
 	}
-	return false, errors.New(fmt.Sprintf("Invalid operator: '%s'", c.Op))
+	return false, c.invalidOp()
 }
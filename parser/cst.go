@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+
+	"githib.com/JeffreyRichter/filter/lexer"
+)
+
+// CST is a lossless concrete syntax tree: every byte of a filter, including its whitespace and comments,
+// is in one of the CST's tokens, either as the token's Symbol or in its Trivia, so String reproduces the
+// filter exactly. A tool can change the filter's text (for example, to rename a property) by changing the
+// Symbol of tokens found through the tree and then calling String.
+type CST struct {
+	Root   *SyntaxNode   // The node for the root of the filter's expression tree
+	Tokens []lexer.Token // All of the filter's tokens; the last is EOF, whose Trivia follows the last expression
+}
+
+// SyntaxNode is a node in a CST.
+type SyntaxNode struct {
+	Node     Node          // The expression tree node
+	Tokens   []lexer.Token // The tokens the node spans; they share storage with the CST's Tokens
+	Children []*SyntaxNode // The nodes for Node's operands in the order they appear in the filter
+}
+
+// span is the range of tokens, tokens[start:end], that a node was parsed from.
+type span struct{ start, end int }
+
+// ParseCST parses filter like Parse but returns a CST instead of just the root of the expression tree.
+func ParseCST(filter string, options Options) (*CST, error) {
+	p := &parser{tokens: lexer.GetTokens(filter), options: options, spans: map[Node]span{}}
+	root, err := p.parseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &CST{Root: p.syntaxNode(root), Tokens: p.tokens}, nil
+}
+
+// track records that n was parsed from tokens[start:end] if building a CST; it returns n.
+func (p *parser) track(n Node, start, end int) Node {
+	if p.spans != nil {
+		p.spans[n] = span{start, end}
+	}
+	return n
+}
+
+// syntaxNode returns the SyntaxNode for n and, recursively, its operands.
+func (p *parser) syntaxNode(n Node) *SyntaxNode {
+	s := p.spans[n]
+	sn := &SyntaxNode{Node: n, Tokens: p.tokens[s.start:s.end:s.end]}
+	Inspect(n, func(child Node) bool {
+		if child == n {
+			return true
+		}
+		sn.Children = append(sn.Children, p.syntaxNode(child))
+		return false
+	})
+	sort.SliceStable(sn.Children, func(i, j int) bool { // A normalized comparison's sides may be swapped
+		return sn.Children[i].Tokens[0].Position.Offset < sn.Children[j].Tokens[0].Position.Offset
+	})
+	return sn
+}
+
+// String returns the filter's text exactly as it was parsed, reflecting any changes to the tokens' Symbols.
+func (c *CST) String() string { return writeTokens(c.Tokens, true) }
+
+// String returns the text of the node's tokens, excluding the whitespace and comments preceding the first one.
+func (n *SyntaxNode) String() string { return writeTokens(n.Tokens, false) }
+
+// writeTokens returns the concatenated text of tokens, including the first token's trivia if leading is true.
+func writeTokens(tokens []lexer.Token, leading bool) string {
+	var sb strings.Builder
+	for i, t := range tokens {
+		if i > 0 || leading {
+			for _, trivia := range t.Trivia {
+				sb.WriteString(trivia.Symbol)
+			}
+		}
+		sb.WriteString(t.Symbol)
+	}
+	return sb.String()
+}
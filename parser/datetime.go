@@ -0,0 +1,190 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date is a calendar date without a time of day or time zone: 2024-05-01
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+func (d Date) String() string { return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day) }
+
+// ordinal returns an integer that orders dates chronologically
+func (d Date) ordinal() int64 { return int64(d.Year)*10000 + int64(d.Month)*100 + int64(d.Day) }
+
+// dateOf returns the date portion of t in t's location.
+func dateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+// parseDate parses s in the form yyyy-mm-dd.
+func parseDate(s string) (Date, error) {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return Date{}, fmt.Errorf("Date has improper syntax: '%s'", s)
+	}
+	return dateOf(t), nil
+}
+
+// TimeOfDay is a time within a day without a date or time zone; it is the duration since midnight.
+type TimeOfDay time.Duration
+
+func (t TimeOfDay) String() string {
+	d := time.Duration(t)
+	s := fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	if ns := d % time.Second; ns != 0 {
+		s += fmt.Sprintf(".%09d", ns)
+	}
+	return s
+}
+
+// timeOfDayOf returns the time of day portion of t in t's location.
+func timeOfDayOf(t time.Time) TimeOfDay {
+	h, m, s := t.Clock()
+	return TimeOfDay(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute +
+		time.Duration(s)*time.Second + time.Duration(t.Nanosecond()))
+}
+
+// parseTimeOfDay parses s in the form hh:mm[:ss[.fraction]].
+func parseTimeOfDay(s string) (TimeOfDay, error) {
+	layout := "15:04:05.999999999"
+	if len(s) == len("15:04") {
+		layout = "15:04"
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return 0, fmt.Errorf("Time of day has improper syntax: '%s'", s)
+	}
+	return timeOfDayOf(t), nil
+}
+
+// durationRegexp matches an ISO 8601 duration with days, hours, minutes, and seconds: -P1DT2H30M15.5S
+var durationRegexp = regexp.MustCompile(`^(-)?P(?:([0-9]+)D)?(?:T(?:([0-9]+)H)?(?:([0-9]+)M)?(?:([0-9]+(?:\.[0-9]+)?)S)?)?$`)
+
+// parseDuration parses s, an ISO 8601 duration; years and months aren't allowed since their lengths vary.
+func parseDuration(s string) (time.Duration, error) {
+	m := durationRegexp.FindStringSubmatch(s)
+	if m == nil || strings.HasSuffix(s, "P") || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("Duration has improper syntax: '%s'", s)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+2] == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(m[i+2], 64)
+		if part := n * float64(unit); err != nil || part+float64(d) > math.MaxInt64 {
+			return 0, fmt.Errorf("Duration out of range: '%s'", s)
+		}
+		d += time.Duration(n * float64(unit))
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+// evaluateYear returns the year of the argument (a time or date).
+func evaluateYear(c *Call, env Environment, args []any) (any, error) {
+	switch v := args[0].(type) {
+	case time.Time:
+		return int64(v.Year()), nil
+	case Date:
+		return int64(v.Year), nil
+	}
+	return nil, argTypeError(c, 0, args[0], "time or date")
+}
+
+// evaluateMonth returns the month (1-12) of the argument (a time or date).
+func evaluateMonth(c *Call, env Environment, args []any) (any, error) {
+	switch v := args[0].(type) {
+	case time.Time:
+		return int64(v.Month()), nil
+	case Date:
+		return int64(v.Month), nil
+	}
+	return nil, argTypeError(c, 0, args[0], "time or date")
+}
+
+// evaluateDay returns the day of the month (1-31) of the argument (a time or date).
+func evaluateDay(c *Call, env Environment, args []any) (any, error) {
+	switch v := args[0].(type) {
+	case time.Time:
+		return int64(v.Day()), nil
+	case Date:
+		return int64(v.Day), nil
+	}
+	return nil, argTypeError(c, 0, args[0], "time or date")
+}
+
+// evaluateHour returns the hour (0-23) of the argument (a time or time of day).
+func evaluateHour(c *Call, env Environment, args []any) (any, error) {
+	return clockPart(c, args, time.Hour, 24)
+}
+
+// evaluateMinute returns the minute (0-59) of the argument (a time or time of day).
+func evaluateMinute(c *Call, env Environment, args []any) (any, error) {
+	return clockPart(c, args, time.Minute, 60)
+}
+
+// evaluateSecond returns the second (0-59) of the argument (a time or time of day).
+func evaluateSecond(c *Call, env Environment, args []any) (any, error) {
+	return clockPart(c, args, time.Second, 60)
+}
+
+// clockPart returns the number of whole units (modulo max) in the argument's time of day.
+func clockPart(c *Call, args []any, unit time.Duration, max int64) (any, error) {
+	var t TimeOfDay
+	switch v := args[0].(type) {
+	case time.Time:
+		t = timeOfDayOf(v)
+	case TimeOfDay:
+		t = v
+	default:
+		return nil, argTypeError(c, 0, args[0], "time or time of day")
+	}
+	return int64(time.Duration(t)/unit) % max, nil
+}
+
+// evaluateDate returns the date portion of the argument (a time).
+func evaluateDate(c *Call, env Environment, args []any) (any, error) {
+	t, ok := args[0].(time.Time)
+	if !ok {
+		return nil, argTypeError(c, 0, args[0], "time")
+	}
+	return dateOf(t), nil
+}
+
+// evaluateTime returns the time of day portion of the argument (a time).
+func evaluateTime(c *Call, env Environment, args []any) (any, error) {
+	t, ok := args[0].(time.Time)
+	if !ok {
+		return nil, argTypeError(c, 0, args[0], "time")
+	}
+	return timeOfDayOf(t), nil
+}
+
+// evaluateTotalOffsetMinutes returns the number of minutes the argument's (a time) time zone is offset from UTC.
+func evaluateTotalOffsetMinutes(c *Call, env Environment, args []any) (any, error) {
+	t, ok := args[0].(time.Time)
+	if !ok {
+		return nil, argTypeError(c, 0, args[0], "time")
+	}
+	_, offset := t.Zone()
+	return int64(offset / 60), nil
+}
+
+// evaluateNow returns the environment's current time.
+func evaluateNow(c *Call, env Environment, args []any) (any, error) {
+	return env.Now, nil
+}
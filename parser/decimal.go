@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DecimalNumber is implemented by a type that represents an exact decimal number, Coefficient × 10^Exponent,
+// such as Decimal or github.com/shopspring/decimal's Decimal. Evaluate converts a value that implements
+// DecimalNumber to a Decimal, so any decimal package can supply a filter's values.
+type DecimalNumber interface {
+	Coefficient() *big.Int
+	Exponent() int32
+}
+
+// Decimal is an exact decimal number, such as an amount of money: 19.99 is exactly 1999 × 10^-2.
+// A decimal literal evaluates to a Decimal, and adding, subtracting, multiplying, or comparing decimals
+// and integers is exact: 0.1 add 0.2 eq 0.3 is true. A Decimal is immutable; the zero value is 0.
+type Decimal struct {
+	coefficient *big.Int // nil means 0
+	exponent    int32
+}
+
+// maxDecimalExponent limits the exponent of a parsed decimal so that aligning two decimals' coefficients
+// can't take unbounded memory; it is IEEE 754 decimal128's limit.
+const maxDecimalExponent = 6144
+
+// decimalQuoDigits is the number of significant digits in a quotient that isn't an exact decimal (like 1 div 3);
+// it is IEEE 754 decimal128's precision.
+const decimalQuoDigits = 34
+
+// NewDecimal returns the Decimal coefficient × 10^exponent.
+func NewDecimal(coefficient *big.Int, exponent int32) Decimal {
+	return Decimal{coefficient: new(big.Int).Set(coefficient), exponent: exponent}
+}
+
+// ParseDecimal parses s, a decimal number with an optional sign and exponent: 19.99, -.5, 1.5e3
+func ParseDecimal(s string) (Decimal, error) {
+	mantissa, exponent := s, int64(0)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("Decimal has improper syntax: '%s'", s)
+		}
+		mantissa, exponent = s[:i], e
+	}
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		exponent -= int64(len(mantissa) - i - 1)
+		mantissa = mantissa[:i] + mantissa[i+1:]
+	}
+	c, ok := new(big.Int).SetString(mantissa, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("Decimal has improper syntax: '%s'", s)
+	}
+	if exponent < -maxDecimalExponent || exponent > maxDecimalExponent {
+		return Decimal{}, fmt.Errorf("Decimal out of range: '%s'", s)
+	}
+	return Decimal{coefficient: c, exponent: int32(exponent)}, nil
+}
+
+// Coefficient returns a copy of the decimal's coefficient.
+func (d Decimal) Coefficient() *big.Int { return new(big.Int).Set(d.coeff()) }
+
+// Exponent returns the power of 10 that the decimal's coefficient is multiplied by.
+func (d Decimal) Exponent() int32 { return d.exponent }
+
+// String returns the decimal without an exponent: 19.99, 0.30, 1500
+func (d Decimal) String() string {
+	c := d.coeff()
+	if d.exponent >= 0 {
+		return new(big.Int).Mul(c, pow10(int64(d.exponent))).String()
+	}
+	digits, sign := new(big.Int).Abs(c).String(), ""
+	if c.Sign() < 0 {
+		sign = "-"
+	}
+	scale := int(-d.exponent)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// coeff returns the decimal's coefficient, which the caller must not modify.
+func (d Decimal) coeff() *big.Int {
+	if d.coefficient == nil {
+		return new(big.Int)
+	}
+	return d.coefficient
+}
+
+// rat returns the decimal as an exact rational number.
+func (d Decimal) rat() *big.Rat {
+	r := new(big.Rat).SetInt(d.coeff())
+	if d.exponent >= 0 {
+		return r.Mul(r, new(big.Rat).SetInt(pow10(int64(d.exponent))))
+	}
+	return r.Quo(r, new(big.Rat).SetInt(pow10(-int64(d.exponent))))
+}
+
+// align returns d's and n's coefficients scaled to their smaller exponent, which it also returns.
+func (d Decimal) align(n Decimal) (dc, nc *big.Int, exponent int32) {
+	dc, nc, exponent = d.coeff(), n.coeff(), d.exponent
+	switch {
+	case d.exponent > n.exponent:
+		dc, exponent = new(big.Int).Mul(dc, pow10(int64(d.exponent)-int64(n.exponent))), n.exponent
+	case d.exponent < n.exponent:
+		nc = new(big.Int).Mul(nc, pow10(int64(n.exponent)-int64(d.exponent)))
+	}
+	return dc, nc, exponent
+}
+
+func (d Decimal) add(n Decimal) Decimal {
+	dc, nc, exponent := d.align(n)
+	return Decimal{coefficient: new(big.Int).Add(dc, nc), exponent: exponent}
+}
+
+func (d Decimal) sub(n Decimal) Decimal {
+	dc, nc, exponent := d.align(n)
+	return Decimal{coefficient: new(big.Int).Sub(dc, nc), exponent: exponent}
+}
+
+func (d Decimal) mul(n Decimal) Decimal {
+	return Decimal{coefficient: new(big.Int).Mul(d.coeff(), n.coeff()), exponent: d.exponent + n.exponent}
+}
+
+// quo returns d / n (n must not be 0): exactly if the quotient is a decimal; otherwise, rounded
+// half to even to decimalQuoDigits significant digits.
+func (d Decimal) quo(n Decimal) Decimal {
+	return decimalOfRat(new(big.Rat).Quo(d.rat(), n.rat()))
+}
+
+// rem returns d - n × trunc(d / n) (n must not be 0), which has the sign of d.
+func (d Decimal) rem(n Decimal) Decimal {
+	q := new(big.Rat).Quo(d.rat(), n.rat())
+	return d.sub(n.mul(Decimal{coefficient: new(big.Int).Quo(q.Num(), q.Denom())}))
+}
+
+// decimalOf converts n (an int64, *big.Int, or Decimal returned from toNumber) to a Decimal.
+func decimalOf(n any) Decimal {
+	switch n := n.(type) {
+	case int64:
+		return Decimal{coefficient: big.NewInt(n)}
+	case *big.Int:
+		return Decimal{coefficient: n}
+	}
+	return n.(Decimal)
+}
+
+// decimalOfRat converts r to a Decimal: exactly if r's denominator has no prime factors but 2 and 5;
+// otherwise, rounded half to even to decimalQuoDigits significant digits.
+func decimalOfRat(r *big.Rat) Decimal {
+	rest, scale := new(big.Int).Set(r.Denom()), int64(0)
+	for _, p := range []int64{2, 5} { // scale is the larger power of 2 or 5 in the denominator
+		n, m := int64(0), new(big.Int)
+		for q := big.NewInt(p); ; n++ {
+			if rest.QuoRem(rest, q, m); m.Sign() != 0 {
+				rest.Mul(rest, q).Add(rest, m) // Undo the inexact division
+				break
+			}
+		}
+		if n > scale {
+			scale = n
+		}
+	}
+	if rest.Cmp(big.NewInt(1)) != 0 { // Not a decimal; scale for decimalQuoDigits digits in all
+		scale = decimalQuoDigits - int64(len(new(big.Int).Abs(r.Num()).String())-len(r.Denom().String()))
+	}
+	num, den := new(big.Int).Set(r.Num()), new(big.Int).Set(r.Denom())
+	if scale >= 0 {
+		num.Mul(num, pow10(scale))
+	} else {
+		den.Mul(den, pow10(-scale))
+	}
+	c, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	if m.Sign() != 0 { // Round half to even
+		twice := new(big.Int).Abs(new(big.Int).Lsh(m, 1))
+		if cmp := twice.Cmp(den); cmp > 0 || (cmp == 0 && c.Bit(0) == 1) {
+			c.Add(c, big.NewInt(int64(r.Sign())))
+		}
+	}
+	return Decimal{coefficient: c, exponent: int32(-scale)}
+}
+
+// pow10 returns 10^n (n must not be negative).
+func pow10(n int64) *big.Int { return new(big.Int).Exp(big.NewInt(10), big.NewInt(n), nil) }
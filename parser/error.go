@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"githib.com/JeffreyRichter/filter/lexer"
+)
+
+// SyntaxError describes a problem with a filter's text and where the problem is.
+type SyntaxError struct {
+	Msg      string         // Describes the problem
+	Position lexer.Position // Where the problem is in the filter
+	Filter   string         // The filter's text
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s (line %d, column %d)", e.Msg, e.Position.Line, e.Position.Column)
+}
+
+// Caret returns the filter's line containing the problem with a ^ beneath the problem's column:
+//
+//	name eq 'Jeff' xx 5
+//	               ^
+func (e *SyntaxError) Caret() string {
+	start := strings.LastIndexByte(e.Filter[:e.Position.Offset], '\n') + 1
+	end := strings.IndexByte(e.Filter[start:], '\n')
+	if end < 0 {
+		end = len(e.Filter) - start
+	}
+	line := strings.TrimSuffix(e.Filter[start:start+end], "\r")
+	var indent strings.Builder
+	for _, r := range e.Filter[start:e.Position.Offset] {
+		if r == '\t' { // Keep tabs so the caret lines up however tabs are displayed
+			indent.WriteRune(r)
+		} else {
+			indent.WriteByte(' ')
+		}
+	}
+	return line + "\n" + indent.String() + "^"
+}
+
+// errorf returns a SyntaxError at pos; Parse sets the error's Filter.
+func errorf(pos lexer.Position, format string, args ...any) error {
+	return &SyntaxError{Msg: fmt.Sprintf(format, args...), Position: pos}
+}
+
+// Severity indicates whether a Diagnostic prevents a filter from being used.
+type Severity string
+
+const (
+	SeverityError   = Severity("error")   // The filter can't be used
+	SeverityWarning = Severity("warning") // The filter can be used but probably doesn't do what was intended
+)
+
+// Diagnostic is a problem found in a filter by ParseDiagnostics.
+type Diagnostic struct {
+	Severity     Severity
+	*SyntaxError // Describes the problem and where it is
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"githib.com/JeffreyRichter/filter/lexer"
+)
+
+// Call is a call to one of the filter's built-in functions.
+type Call struct {
+	Name     string
+	Args     []Node
+	Position lexer.Position
+}
+
+func (c *Call) Pos() lexer.Position { return c.Position }
+
+func (c *Call) String() string {
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		args[i] = a.String()
+	}
+	return fmt.Sprintf("%s(%s)", c.Name, strings.Join(args, ", "))
+}
+
+// Kind returns the kind of value the function returns.
+func (c *Call) Kind() Kind { return functions[c.Name].result }
+
+// TestsExistence returns true if the function tests whether its argument, a property, exists.
+// The property isn't evaluated; instead, the argument's value passed to Evaluate is whether it exists.
+func (c *Call) TestsExistence() bool { return functions[c.Name].existence }
+
+// Environment supplies the values that some functions need beyond their arguments.
+type Environment struct {
+	Now time.Time // The value returned by now()
+}
+
+// Evaluate invokes the function passing it env and the values of its arguments. If any argument
+// is nil (a property that doesn't exist), a predicate returns false and any other function returns nil.
+func (c *Call) Evaluate(env Environment, args []any) (any, error) {
+	f := functions[c.Name]
+	for _, a := range args {
+		if a == nil {
+			if f.result == KindBoolean {
+				return false, nil
+			}
+			return nil, nil
+		}
+	}
+	return f.evaluate(c, env, args)
+}
+
+// function describes a built-in function.
+type function struct {
+	minArgs, maxArgs int                                                     // The number of arguments the function requires
+	result           Kind                                                    // The kind of value the function returns
+	volatile         bool                                                    // true if the result can differ between evaluations
+	existence        bool                                                    // true if the argument is a property whose existence is tested
+	evaluate         func(c *Call, env Environment, args []any) (any, error) // Computes the function's result from its argument values
+}
+
+// functions maps each built-in function's name to its description.
+var functions = map[string]function{
+	"contains":   {minArgs: 2, maxArgs: 2, result: KindBoolean, evaluate: evaluateContains},
+	"startswith": {minArgs: 2, maxArgs: 2, result: KindBoolean, evaluate: evaluateStartsWith},
+	"endswith":   {minArgs: 2, maxArgs: 2, result: KindBoolean, evaluate: evaluateEndsWith},
+	"length":     {minArgs: 1, maxArgs: 1, result: KindNumber, evaluate: evaluateLength},
+	"indexof":    {minArgs: 2, maxArgs: 2, result: KindNumber, evaluate: evaluateIndexOf},
+	"substring":  {minArgs: 2, maxArgs: 3, result: KindString, evaluate: evaluateSubstring},
+	"tolower":    {minArgs: 1, maxArgs: 1, result: KindString, evaluate: evaluateToLower},
+	"toupper":    {minArgs: 1, maxArgs: 1, result: KindString, evaluate: evaluateToUpper},
+	"trim":       {minArgs: 1, maxArgs: 1, result: KindString, evaluate: evaluateTrim},
+	"concat":     {minArgs: 2, maxArgs: 2, result: KindString, evaluate: evaluateConcat},
+
+	"year":               {minArgs: 1, maxArgs: 1, result: KindNumber, evaluate: evaluateYear},
+	"month":              {minArgs: 1, maxArgs: 1, result: KindNumber, evaluate: evaluateMonth},
+	"day":                {minArgs: 1, maxArgs: 1, result: KindNumber, evaluate: evaluateDay},
+	"hour":               {minArgs: 1, maxArgs: 1, result: KindNumber, evaluate: evaluateHour},
+	"minute":             {minArgs: 1, maxArgs: 1, result: KindNumber, evaluate: evaluateMinute},
+	"second":             {minArgs: 1, maxArgs: 1, result: KindNumber, evaluate: evaluateSecond},
+	"date":               {minArgs: 1, maxArgs: 1, result: KindDate, evaluate: evaluateDate},
+	"time":               {minArgs: 1, maxArgs: 1, result: KindTimeOfDay, evaluate: evaluateTime},
+	"totaloffsetminutes": {minArgs: 1, maxArgs: 1, result: KindNumber, evaluate: evaluateTotalOffsetMinutes},
+	"now":                {minArgs: 0, maxArgs: 0, result: KindTime, volatile: true, evaluate: evaluateNow},
+
+	"round":   {minArgs: 1, maxArgs: 1, result: KindNumber, evaluate: evaluateRound},
+	"floor":   {minArgs: 1, maxArgs: 1, result: KindNumber, evaluate: evaluateFloor},
+	"ceiling": {minArgs: 1, maxArgs: 1, result: KindNumber, evaluate: evaluateCeiling},
+	"abs":     {minArgs: 1, maxArgs: 1, result: KindNumber, evaluate: evaluateAbs},
+	"min":     {minArgs: 2, maxArgs: 2, result: KindNumber, evaluate: evaluateMin},
+	"max":     {minArgs: 2, maxArgs: 2, result: KindNumber, evaluate: evaluateMax},
+
+	"exists":    {minArgs: 1, maxArgs: 1, result: KindBoolean, existence: true, evaluate: evaluateExists},
+	"isdefined": {minArgs: 1, maxArgs: 1, result: KindBoolean, existence: true, evaluate: evaluateExists},
+}
+
+// evaluateExists returns whether its argument, a property, exists: true even if the property's value is null.
+func evaluateExists(c *Call, env Environment, args []any) (any, error) { return args[0], nil }
+
+// argTypeError returns an error indicating that argument i of c has an unsupported value.
+func argTypeError(c *Call, i int, v any, want string) error {
+	return fmt.Errorf("Type mismatch: argument %d of %s is %s='%v' but must be a %s", i+1, c.Name, c.Args[i], v, want)
+}
+
+// stringArg returns argument i of c as a string.
+func stringArg(c *Call, args []any, i int) (string, error) {
+	s, ok := args[i].(string)
+	if !ok {
+		return "", argTypeError(c, i, args[i], "string")
+	}
+	return s, nil
+}
+
+// integerArg returns argument i of c as an int64.
+func integerArg(c *Call, args []any, i int) (int64, error) {
+	n, _ := toNumber(args[i])
+	v, ok := n.(int64)
+	if !ok {
+		return 0, argTypeError(c, i, args[i], "integer")
+	}
+	return v, nil
+}
@@ -0,0 +1,42 @@
+package parser
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// GUID is a globally unique identifier: 01234567-89ab-cdef-0123-456789abcdef
+type GUID [16]byte
+
+func (g GUID) String() string {
+	s := hex.EncodeToString(g[:])
+	return fmt.Sprintf("%s-%s-%s-%s-%s", s[:8], s[8:12], s[12:16], s[16:20], s[20:])
+}
+
+// parseGUID parses s in the form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx where each x is a hex digit in either case.
+func parseGUID(s string) (GUID, error) {
+	var g GUID
+	parts := strings.Split(s, "-")
+	if len(parts) != 5 || len(parts[0]) != 8 || len(parts[1]) != 4 || len(parts[2]) != 4 || len(parts[3]) != 4 || len(parts[4]) != 12 {
+		return g, fmt.Errorf("GUID has improper syntax: '%s'", s)
+	}
+	if _, err := hex.Decode(g[:], []byte(strings.Join(parts, ""))); err != nil {
+		return g, fmt.Errorf("GUID has improper syntax: '%s'", s)
+	}
+	return g, nil
+}
+
+// guidOf converts v (a GUID, a [16]byte, or a string in GUID form) to a GUID; ok is false if it can't.
+func guidOf(v any) (g GUID, ok bool) {
+	switch v := v.(type) {
+	case GUID:
+		return v, true
+	case [16]byte:
+		return GUID(v), true
+	case string:
+		g, err := parseGUID(v)
+		return g, err == nil
+	}
+	return g, false
+}
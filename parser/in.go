@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"githib.com/JeffreyRichter/filter/lexer"
+)
+
+// In tests whether a value is one of a list of literals: status in ('open', 'pending')
+type In struct {
+	Left     Node
+	List     []*Literal       // All the literals have the same kind (see KindOf)
+	set      map[any]bool     // The List's values keyed by setKey; built by newIn
+	kind     Kind             // The kind of all of List's literals
+	floats   map[float64]bool // The List's floats, which a decimal equals if it's the decimal's nearest float64
+	decimals map[float64]bool // The nearest float64 to each of the List's decimals, which a float may equal
+}
+
+func (in *In) Pos() lexer.Position { return in.Left.Pos() }
+
+// newIn returns an In whose list is type-checked and indexed for fast lookup.
+func newIn(left Node, list []*Literal) (*In, error) {
+	in := &In{Left: left, List: list, set: map[any]bool{}, kind: KindOf(list[0].Value),
+		floats: map[float64]bool{}, decimals: map[float64]bool{}}
+	for _, l := range list {
+		if k := KindOf(l.Value); k != in.kind {
+			return nil, errorf(l.Position, "List elements must all be the same type: %s is a %s but %s is a %s", list[0], in.kind, l, k)
+		}
+		switch v := l.Value.(type) {
+		case float64:
+			in.floats[v] = true
+		case Decimal:
+			f, _ := nearestFloat(v)
+			in.decimals[f] = true
+		}
+		in.set[setKey(l.Value)] = true
+	}
+	return in, nil
+}
+
+func (in *In) String() string {
+	list := make([]string, len(in.List))
+	for i, l := range in.List {
+		list[i] = l.String()
+	}
+	return fmt.Sprintf("%s in (%s)", parenthesize(in.Left, precedence(in)+1), strings.Join(list, ", "))
+}
+
+// Evaluate returns true if left (nil if a property doesn't exist) is in the list. If the list includes
+// a decimal, left may be a string in decimal form.
+func (in *In) Evaluate(left any) (bool, error) {
+	if left == nil {
+		return false, nil // Property doesn't exist
+	}
+	if s, ok := left.(string); ok && len(in.decimals) > 0 { // A string in decimal form, as in: s eq 19.99
+		if d, err := ParseDecimal(s); err == nil {
+			left = d
+		}
+	}
+	if n, ok := toNumber(left); ok && in.kind == KindNumber {
+		if in.set[numberKey(n)] { // Numbers are keyed exactly, whatever their types
+			return true, nil
+		}
+		f, _ := nearestFloat(n) // Like compareNumbers, compare a decimal with a float as its nearest float64
+		switch {
+		case isFloat(n):
+			return in.decimals[f], nil
+		case isDecimal(n):
+			return in.floats[f], nil
+		}
+		return false, nil
+	}
+	if g, ok := guidOf(left); ok && in.kind == KindGUID { // left may be a string in GUID form
+		return in.set[g], nil
+	}
+	if KindOf(left) != in.kind {
+		return false, fmt.Errorf("Type mismatch: %s='%v' while list elements are %ss", in.Left, left, in.kind)
+	}
+	return in.set[setKey(left)], nil
+}
+
+// bigIntKey is the map key for a *big.Int
+type bigIntKey string
+
+// ratKey is the map key for a finite number that isn't an integer
+type ratKey string
+
+// setKey returns a map key for v; equal numbers of different types and equal times in different locations
+// have the same key
+func setKey(v any) any {
+	if n, ok := toNumber(v); ok {
+		return numberKey(n)
+	}
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case [16]byte:
+		return GUID(v)
+	case []byte: // Slices can't be map keys
+		return string(v)
+	}
+	return v
+}
+
+// numberKey returns a map key for n (returned from toNumber) that is equal to another number's key only if the
+// numbers are exactly equal: 2, 2.0, and 2e0 have the same key, but 9007199254740993 and 9007199254740992e0 don't.
+func numberKey(n any) any {
+	if isFloat(n) {
+		if f, _ := nearestFloat(n); math.IsInf(f, 0) || math.IsNaN(f) { // Not rational; NaN never equals a key
+			return f
+		}
+	}
+	var r *big.Rat
+	if f, ok := n.(float64); ok {
+		r = new(big.Rat).SetFloat64(f)
+	} else {
+		r = toRat(n)
+	}
+	switch {
+	case !r.IsInt():
+		return ratKey(r.RatString())
+	case r.Num().IsInt64():
+		return r.Num().Int64()
+	}
+	return bigIntKey(r.Num().String()) // Equal big integers are different pointers
+}
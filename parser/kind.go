@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"reflect"
+	"time"
+)
+
+// Kind is the kind of a value produced by a filter expression.
+type Kind string
+
+const (
+	KindNull      = Kind("null")
+	KindBoolean   = Kind("boolean")
+	KindNumber    = Kind("number") // Any Go integer or float type, including a named one such as: type Cents int64
+	KindString    = Kind("string")
+	KindTime      = Kind("time")
+	KindDate      = Kind("date")
+	KindTimeOfDay = Kind("timeOfDay")
+	KindDuration  = Kind("duration") // A time.Duration
+	KindGUID      = Kind("guid")     // A GUID or [16]byte
+	KindBinary    = Kind("binary")   // A []byte
+	KindObject    = Kind("object")   // A map[string]any
+	KindArray     = Kind("array")    // Any Go slice or array
+	KindOther     = Kind("other")
+)
+
+// KindOf returns the kind of value v.
+func KindOf(v any) Kind {
+	if _, ok := toNumber(v); ok {
+		return KindNumber
+	}
+	switch v.(type) {
+	case nil:
+		return KindNull
+	case bool:
+		return KindBoolean
+	case string:
+		return KindString
+	case time.Time:
+		return KindTime
+	case Date:
+		return KindDate
+	case TimeOfDay:
+		return KindTimeOfDay
+	case time.Duration:
+		return KindDuration
+	case GUID, [16]byte:
+		return KindGUID
+	case []byte:
+		return KindBinary
+	case map[string]any:
+		return KindObject
+	}
+	if k := reflect.ValueOf(v).Kind(); k == reflect.Slice || k == reflect.Array {
+		return KindArray
+	}
+	return KindOther
+}
@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// evaluateRound returns the argument (a number) rounded to the nearest integer; halves round away from zero.
+func evaluateRound(c *Call, env Environment, args []any) (any, error) {
+	return roundFunction(c, args, math.Round)
+}
+
+// evaluateFloor returns the largest integer less than or equal to the argument (a number).
+func evaluateFloor(c *Call, env Environment, args []any) (any, error) {
+	return roundFunction(c, args, math.Floor)
+}
+
+// evaluateCeiling returns the smallest integer greater than or equal to the argument (a number).
+func evaluateCeiling(c *Call, env Environment, args []any) (any, error) {
+	return roundFunction(c, args, math.Ceil)
+}
+
+// roundFunction applies round to a float argument; an integer argument is returned unchanged.
+func roundFunction(c *Call, args []any, round func(float64) float64) (any, error) {
+	switch n := numberArg(args[0]).(type) {
+	case int64:
+		return n, nil
+	case float64:
+		return round(n), nil
+	case *big.Int:
+		return n, nil
+	case *big.Float:
+		if n.IsInf() {
+			return n, nil
+		}
+		r, _ := toNumber(roundRat(toRat(n), round))
+		return r, nil
+	case Decimal:
+		return Decimal{coefficient: roundRat(n.rat(), round)}, nil
+	}
+	return nil, argTypeError(c, 0, args[0], "number")
+}
+
+// roundRat returns r rounded to an integer by round (math.Round, math.Floor, or math.Ceil) without converting r
+// to a float64: round is applied to a float with the sign of r's fraction and the same relation to one half.
+func roundRat(r *big.Rat, round func(float64) float64) *big.Int {
+	i := new(big.Int).Quo(r.Num(), r.Denom()) // Truncated toward zero
+	frac := new(big.Rat).Sub(r, new(big.Rat).SetInt(i))
+	f := 0.0
+	switch half := new(big.Rat).Abs(frac).Cmp(big.NewRat(1, 2)); {
+	case frac.Sign() == 0:
+	case half < 0:
+		f = 0.25
+	case half == 0:
+		f = 0.5
+	default:
+		f = 0.75
+	}
+	return i.Add(i, big.NewInt(int64(round(math.Copysign(f, float64(frac.Sign()))))))
+}
+
+// evaluateAbs returns the absolute value of the argument (a number).
+func evaluateAbs(c *Call, env Environment, args []any) (any, error) {
+	switch n := numberArg(args[0]).(type) {
+	case int64:
+		if n == math.MinInt64 {
+			return nil, fmt.Errorf("Integer overflow: %s", c)
+		}
+		if n < 0 {
+			return -n, nil
+		}
+		return n, nil
+	case float64:
+		return math.Abs(n), nil
+	case *big.Int:
+		return new(big.Int).Abs(n), nil
+	case *big.Float:
+		return new(big.Float).Abs(n), nil
+	case Decimal:
+		return Decimal{coefficient: new(big.Int).Abs(n.coeff()), exponent: n.exponent}, nil
+	}
+	return nil, argTypeError(c, 0, args[0], "number")
+}
+
+// evaluateMin returns the smaller of the 2 arguments (numbers).
+func evaluateMin(c *Call, env Environment, args []any) (any, error) {
+	return minMax(c, args, true)
+}
+
+// evaluateMax returns the larger of the 2 arguments (numbers).
+func evaluateMax(c *Call, env Environment, args []any) (any, error) {
+	return minMax(c, args, false)
+}
+
+// minMax returns the smaller (if min is true) or larger of the 2 arguments.
+// The result is an integer if both arguments are integers; if either is big or a decimal, it is the argument
+// itself; otherwise it is a float. If either argument is NaN, the result is NaN, as with math.Min and math.Max.
+func minMax(c *Call, args []any, min bool) (any, error) {
+	l, r := numberArg(args[0]), numberArg(args[1])
+	for i, n := range []any{l, r} {
+		if n == nil {
+			return nil, argTypeError(c, i, args[i], "number")
+		}
+	}
+	li, lInt := l.(int64)
+	ri, rInt := r.(int64)
+	if lInt && rInt {
+		if (li < ri) == min {
+			return li, nil
+		}
+		return ri, nil
+	}
+	if isNaN(l) || isNaN(r) {
+		return math.NaN(), nil
+	}
+	if isBig(l) || isBig(r) || isDecimal(l) || isDecimal(r) {
+		cmp, _ := compareNumbers(l, r)
+		if (cmp < 0) == min {
+			return l, nil
+		}
+		return r, nil
+	}
+	if min {
+		return math.Min(toFloat(l), toFloat(r)), nil
+	}
+	return math.Max(toFloat(l), toFloat(r)), nil
+}
+
+// numberArg returns v as a number returned from toNumber; nil if v isn't a number.
+func numberArg(v any) any {
+	n, _ := toNumber(v)
+	return n
+}
@@ -0,0 +1,235 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"githib.com/JeffreyRichter/filter/lexer"
+)
+
+// Node is a node of the filter's expression tree.
+type Node interface {
+	String() string      // Returns the node (and its children) as filter text
+	Pos() lexer.Position // Returns the position of the node's first token in the filter
+}
+
+// LogicalOp represents a logical operator and provides some type safety.
+type LogicalOp string
+
+const (
+	LogicalAnd = LogicalOp("and")
+	LogicalOr  = LogicalOp("or")
+)
+
+// Logical is a binary logical operation: Left and Right, Left or Right.
+type Logical struct {
+	Op          LogicalOp
+	Left, Right Node
+}
+
+func (l *Logical) Pos() lexer.Position { return l.Left.Pos() }
+
+func (l *Logical) String() string {
+	return fmt.Sprintf("%s %s %s", parenthesize(l.Left, precedence(l)), l.Op, parenthesize(l.Right, precedence(l)))
+}
+
+// Not is the logical negation of its operand.
+type Not struct {
+	Operand  Node
+	Position lexer.Position
+}
+
+func (n *Not) Pos() lexer.Position { return n.Position }
+
+func (n *Not) String() string {
+	switch n.Operand.(type) {
+	case *Not, *Call, *Lambda:
+		return "not " + n.Operand.String()
+	}
+	return "not (" + n.Operand.String() + ")"
+}
+
+// Property is a reference to a JSON property; each path element steps into a child object or,
+// if it is an array index (see ArrayIndex), into an array element.
+type Property struct {
+	Variable  string   // The lambda range variable the Path is relative to; "" for the filtered object
+	Path      []string // Empty if the property is the range variable itself
+	Position  lexer.Position
+	separator string // The only path separator allowed when the property was parsed; "" if both are allowed
+	shadowed  bool   // Variable is "" but the first name is the range variable of an enclosing lambda
+}
+
+func (p *Property) Pos() lexer.Position { return p.Position }
+
+func (p *Property) String() string {
+	if p.shadowed { // The name alone would refer to the range variable: tags/any(x: /x eq 1)
+		if pointer, ok := jsonPointer(p.Path); ok {
+			return pointer
+		}
+	}
+	sep, variableSep := ".", "/"
+	if p.separator != "" {
+		sep, variableSep = p.separator, p.separator
+	}
+	var sb strings.Builder
+	sb.WriteString(p.Variable)
+	for i, name := range p.Path {
+		_, isIndex := ArrayIndex(name)
+		switch {
+		case isIndex && sb.Len() > 0:
+			sb.WriteString("[" + name + "]")
+			continue
+		case i == 0 && p.Variable != "":
+			sb.WriteString(variableSep)
+		case i > 0:
+			sb.WriteString(sep)
+		}
+		if i == 0 && p.shadowed {
+			sb.WriteString(quote(name))
+			continue
+		}
+		sb.WriteString(quoteName(name))
+	}
+	return sb.String()
+}
+
+// escapePointer replaces the characters that must be escaped in a JSON Pointer's names; see pointerEscapes
+var escapePointer = strings.NewReplacer("~", "~0", "/", "~1")
+
+// jsonPointer returns path as a JSON Pointer: /a~1b/c; ok is false if a name has a character that
+// can't appear in a JSON Pointer.
+func jsonPointer(path []string) (pointer string, ok bool) {
+	var sb strings.Builder
+	for _, name := range path {
+		if strings.ContainsAny(name, "(),") || strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+			return "", false
+		}
+		sb.WriteString("/" + escapePointer.Replace(name))
+	}
+	return sb.String(), true
+}
+
+// quoteName returns name in double quotes (doubling any double quotes within it) if it isn't a valid symbol
+// or could be mistaken for a keyword or literal.
+func quoteName(name string) string {
+	switch lower := strings.ToLower(name); {
+	case !lexer.IsSymbol(name), keywords[lower], lower == "null", lower == "true", lower == "false":
+		return quote(name)
+	}
+	return name
+}
+
+// quote returns name in double quotes, doubling any double quotes within it.
+func quote(name string) string { return `"` + strings.ReplaceAll(name, `"`, `""`) + `"` }
+
+// LambdaOp represents a lambda operator and provides some type safety.
+type LambdaOp string
+
+const (
+	LambdaAny = LambdaOp("any")
+	LambdaAll = LambdaOp("all")
+)
+
+// Lambda tests a predicate against the elements of an array: tags/any(t: t eq 'urgent')
+// Within the Predicate, properties relative to the Variable refer to the element being tested.
+type Lambda struct {
+	Collection *Property // The array whose elements are tested
+	Op         LambdaOp
+	Variable   string // The range variable; "" if Predicate is nil
+	Predicate  Node   // nil for any(), which is true if the array isn't empty
+}
+
+func (l *Lambda) Pos() lexer.Position { return l.Collection.Position }
+
+func (l *Lambda) String() string {
+	if l.Predicate == nil {
+		return fmt.Sprintf("%s/%s()", l.Collection, l.Op)
+	}
+	return fmt.Sprintf("%s/%s(%s: %s)", l.Collection, l.Op, l.Variable, l.Predicate)
+}
+
+// Literal is a constant value appearing in the filter. Its Value is one of: nil, bool, int64, *big.Int (an integer
+// outside int64's range), float64 (including NaN and ±Inf), Decimal, string, time.Time, Date, TimeOfDay,
+// time.Duration, GUID, or []byte.
+type Literal struct {
+	lexer.Token     // The token the literal was parsed from
+	Value       any // The literal's Go value
+}
+
+func (l *Literal) String() string { return l.Symbol }
+
+func (l *Literal) Pos() lexer.Position { return l.Position }
+
+// bad stands in for an operand with errors so that ParseDiagnostics can keep parsing.
+type bad struct {
+	Position lexer.Position
+}
+
+func (b *bad) String() string { return "<bad>" }
+
+func (b *bad) Pos() lexer.Position { return b.Position }
+
+// precedence returns the binding strength of n's operator; higher binds tighter.
+func precedence(n Node) int {
+	switch n := n.(type) {
+	case *Logical:
+		if n.Op == LogicalOr {
+			return 1
+		}
+		return 2
+	case *Not:
+		return 3
+	case *Comparison, *In:
+		return 4
+	case *Arithmetic:
+		if n.Op.isAdditive() {
+			return 5
+		}
+		return 6
+	}
+	return 7 // Operands: calls, properties, and literals
+}
+
+// parenthesize returns n as text, parenthesized if it binds looser than min.
+func parenthesize(n Node, min int) string {
+	if precedence(n) < min {
+		return "(" + n.String() + ")"
+	}
+	return n.String()
+}
+
+// Inspect traverses the tree rooted at n in depth-first order calling f for each node.
+// If f returns false, Inspect doesn't visit the node's children.
+func Inspect(n Node, f func(Node) bool) {
+	if !f(n) {
+		return
+	}
+	switch n := n.(type) {
+	case *Logical:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *Not:
+		Inspect(n.Operand, f)
+	case *Comparison:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *Arithmetic:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *In:
+		Inspect(n.Left, f)
+		for _, l := range n.List {
+			Inspect(l, f)
+		}
+	case *Call:
+		for _, a := range n.Args {
+			Inspect(a, f)
+		}
+	case *Lambda:
+		Inspect(n.Collection, f)
+		if n.Predicate != nil {
+			Inspect(n.Predicate, f)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package parser
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"time"
+)
+
+// toNumber converts any Go number to one of: an int64 (any integer in int64's range), a float64, a *big.Int
+// (an integer outside int64's range, such as a large uint64), a *big.Float, or a Decimal (from any DecimalNumber
+// whose exponent is within maxDecimalExponent). A json.Number becomes an int64, a *big.Int, or, if it has a
+// fraction or exponent, a Decimal (or a float64 if its exponent is too large). A named integer or float type
+// (type Cents int64) is a number too, except time.Duration and TimeOfDay. ok is false if v is not a number.
+func toNumber(v any) (n any, ok bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case float64:
+		return n, true
+	case time.Duration, TimeOfDay: // Integers underneath but compared only with durations and times of day
+		return nil, false
+	case *big.Int:
+		if n.IsInt64() {
+			return n.Int64(), true
+		}
+		return n, true
+	case *big.Float:
+		return n, true
+	case Decimal:
+		return n, true
+	case DecimalNumber:
+		if e := n.Exponent(); e >= -maxDecimalExponent && e <= maxDecimalExponent {
+			return Decimal{coefficient: n.Coefficient(), exponent: e}, true
+		}
+		return nil, false
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+		if i, ok := new(big.Int).SetString(string(n), 10); ok {
+			return i, true
+		}
+		if d, err := ParseDecimal(string(n)); err == nil {
+			return d, true
+		}
+		if f, err := n.Float64(); err == nil {
+			return f, true
+		}
+		return nil, false
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() { // Any other integer or float type, including a named one
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return int64(u), true
+		} else {
+			return new(big.Int).SetUint64(u), true
+		}
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return nil, false
+}
+
+// toFloat converts n (an int64 or float64 returned from toNumber) to a float64.
+func toFloat(n any) float64 {
+	if i, ok := n.(int64); ok {
+		return float64(i)
+	}
+	return n.(float64)
+}
+
+// isBig returns true if n (returned from toNumber) is a *big.Int or *big.Float.
+// A Decimal isn't big; it is handled on its own (see isDecimal).
+func isBig(n any) bool {
+	switch n.(type) {
+	case *big.Int, *big.Float:
+		return true
+	}
+	return false
+}
+
+// isDecimal returns true if n (returned from toNumber) is a Decimal.
+func isDecimal(n any) bool {
+	_, ok := n.(Decimal)
+	return ok
+}
+
+// toBigFloat converts n (returned from toNumber, but not NaN) to a *big.Float exactly
+// (a Decimal is rounded if it isn't a binary fraction).
+func toBigFloat(n any) *big.Float {
+	switch n := n.(type) {
+	case int64:
+		return new(big.Float).SetInt64(n)
+	case float64:
+		return new(big.Float).SetFloat64(n)
+	case *big.Int:
+		return new(big.Float).SetInt(n)
+	case Decimal:
+		return new(big.Float).SetRat(n.rat())
+	}
+	return n.(*big.Float)
+}
+
+// toBigInt converts n (returned from toNumber) to a *big.Int; ok is false if n isn't an int64 or *big.Int.
+func toBigInt(n any) (i *big.Int, ok bool) {
+	switch n := n.(type) {
+	case int64:
+		return big.NewInt(n), true
+	case *big.Int:
+		return n, true
+	}
+	return nil, false
+}
+
+// nearestFloat converts n (returned from toNumber) to the nearest float64; exact is false if it isn't n.
+func nearestFloat(n any) (f float64, exact bool) {
+	if f, ok := n.(float64); ok {
+		return f, true
+	}
+	if d, ok := n.(Decimal); ok {
+		return d.rat().Float64()
+	}
+	f, accuracy := toBigFloat(n).Float64()
+	return f, accuracy == big.Exact
+}
+
+// compareNumbers compares l and r (returned from toNumber) exactly, whatever their types: an int64 compared with
+// a float64 isn't rounded to a float64 first. The exception is a Decimal compared with a float64 (or an infinite
+// *big.Float): the decimal is rounded to the nearest float64, so float eq 0.1 is true if float is the float64 0.1.
+// It returns -1, 0, or +1; ok is false if either number is NaN.
+func compareNumbers(l, r any) (cmp int, ok bool) {
+	li, lInt := l.(int64)
+	ri, rInt := r.(int64)
+	switch {
+	case lInt && rInt:
+		switch {
+		case li < ri:
+			return -1, true
+		case li > ri:
+			return 1, true
+		}
+		return 0, true
+	case isNaN(l) || isNaN(r):
+		return 0, false
+	case isDecimal(l) || isDecimal(r):
+		if isFloat(l) || isFloat(r) {
+			lf, _ := nearestFloat(l)
+			rf, _ := nearestFloat(r)
+			return compareNumbers(lf, rf)
+		}
+		return toRat(l).Cmp(toRat(r)), true
+	}
+	return toBigFloat(l).Cmp(toBigFloat(r)), true
+}
+
+// isFloat returns true if n (returned from toNumber) is a float64 or an infinite *big.Float.
+func isFloat(n any) bool {
+	switch n := n.(type) {
+	case float64:
+		return true
+	case *big.Float:
+		return n.IsInf()
+	}
+	return false
+}
+
+// toRat converts n (returned from toNumber, but not a float64 or an infinite *big.Float) to a *big.Rat exactly.
+func toRat(n any) *big.Rat {
+	switch n := n.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(n)
+	case *big.Int:
+		return new(big.Rat).SetInt(n)
+	case *big.Float:
+		r, _ := n.Rat(nil)
+		return r
+	}
+	return n.(Decimal).rat()
+}
+
+// isNaN returns true if n (returned from toNumber) is NaN.
+func isNaN(n any) bool {
+	f, ok := n.(float64)
+	return ok && math.IsNaN(f)
+}
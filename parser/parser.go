@@ -1,174 +1,807 @@
 package parser
 
 import (
-	"errors"
+	"encoding/base64"
 	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"githib.com/JeffreyRichter/filter/lexer"
 )
 
-//var showNode = func(node Node) { fmt.Printf("%#v\n", node) }
+// parser is a recursive-descent parser that builds an expression tree from a filter's tokens.
+// The grammar, from lowest to highest precedence, is:
+//
+//	or             = and { "or" and }
+//	and            = not { "and" not }
+//	not            = "not" not | comparison
+//	comparison     = additive [ compareOp additive | "in" "(" literal { "," literal } ")" ]
+//	additive       = multiplicative { ( "add" | "sub" ) multiplicative }
+//	multiplicative = primary { ( "mul" | "div" | "divby" | "mod" ) primary }
+//	primary        = "(" or ")" | call | literal | property [ "/" lambda ]
+//	property       = name { ( "/" | "." ) ( name | index ) | "[" index "]" } | pointer
+//	name           = symbol | quotedName
+//	lambda         = ( "any" | "all" ) "(" variable ":" or ")" | "any" "(" ")"
+//	call           = name "(" [ additive { "," additive } ] ")"
+//
+// A comparison whose left side is constant and right side isn't is normalized by swapping its sides.
+//
+// When recovering, an error in an operand of and/or is reported and the parser skips to the next
+// and, or, or ')' at the operand's parenthesis depth, substituting a bad node for the operand.
+type parser struct {
+	tokens      []lexer.Token // Tokens being parsed
+	pos         int           // current token
+	depth       int           // The number of '(' consumed but not yet closed by a ')'
+	variables   []string      // The range variables of the lambdas enclosing the current token
+	options     Options       // Options passed to Parse
+	recovering  bool          // true to report errors in diagnostics and keep parsing
+	diagnostics []Diagnostic  // The problems found so far when recovering
+	spans       map[Node]span // The tokens each node spans; nil unless building a CST
+}
 
-var showNode = func(node Node) {}
+// next reads the next token; once the last token (EOF) is reached, it is returned forever
+func (p *parser) next() (token lexer.Token) {
+	token = p.tokens[p.pos]
+	if p.pos < len(p.tokens)-1 {
+		p.pos++
+	}
+	switch token.TokenKind {
+	case lexer.TokenLeftParen:
+		p.depth++
+	case lexer.TokenRightParen:
+		p.depth--
+	}
+	return token
+}
 
-// NodeKind represents a lexical token.
-type NodeKind string
+// peek returns the next token without consuming it
+func (p *parser) peek() lexer.Token { return p.lookahead(0) }
 
-const (
-	NodeError      = NodeKind("Error")
-	NodeEOF        = NodeKind("EOF")
-	NodeLeftParen  = NodeKind("(")
-	NodeRightParen = NodeKind(")")
-	NodeAnd        = NodeKind("And")
-	NodeOr         = NodeKind("Or")
-	NodeComparison = NodeKind("Compare")
-	NodeContains   = NodeKind("Contains")
-)
+// lookahead returns the token n tokens after the next token without consuming any
+func (p *parser) lookahead(n int) lexer.Token {
+	if p.pos+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+n]
+}
 
-// Node represents a node of the filter expression.
-type Node struct {
-	NodeKind
-	Comparison // If Kind == PropCompare, this field is set
-	Contains   // If Kind == Contains, this field is set
-	Error      error
+func (p *parser) acceptOne(tokenKind lexer.TokenKind) bool {
+	if p.peek().TokenKind == tokenKind {
+		p.next()
+		return true
+	}
+	return false
 }
 
-type typeMismatchError struct {
-	msg string
+// describe returns t's text for an error message; "end of filter" for EOF.
+func describe(t lexer.Token) string {
+	if t.TokenKind == lexer.TokenEOF {
+		return "end of filter"
+	}
+	return t.Symbol
 }
 
-func (e *typeMismatchError) SetMsg(c Comparison, jsonVal any, t lexer.Token) error {
-	return typeMismatchError{msg: fmt.Sprintf("Type mismatch: PropName(%s)='%v' while literal(%s)='%s'", c.PropName, jsonVal, c.Literal.TokenKind, c.Literal.Symbol)}
+// acceptKeyword consumes the next token if it is the symbol keyword
+func (p *parser) acceptKeyword(keyword string) bool {
+	if t := p.peek(); t.TokenKind == lexer.TokenSymbol && p.keyword(t) == keyword {
+		p.next()
+		return true
+	}
+	return false
 }
 
-func (e typeMismatchError) Error() string { return e.msg }
+// keyword returns t's symbol for comparing with keywords, operators, function names, null, true, false, and
+// the type name prefixing a typed string; these are lowercase if the CaseInsensitive option is set.
+func (p *parser) keyword(t lexer.Token) string {
+	if p.options.CaseInsensitive {
+		switch t.TokenKind {
+		case lexer.TokenSymbol:
+			return strings.ToLower(t.Symbol)
+		case lexer.TokenTypedString: // Time'...' is time'...' but the string itself keeps its case
+			i := strings.IndexByte(t.Symbol, '\'')
+			return strings.ToLower(t.Symbol[:i]) + t.Symbol[i:]
+		}
+	}
+	return t.Symbol
+}
 
-// Parser scans tokens finding its nodes
-type parser struct {
-	tokens []lexer.Token // Tokens being parsed
-	pos    int           // current token
-	start  int           // start token for a node
-	nodes  []Node        // Nodes created from the tokens
+// Parse parses filter and returns the root of its expression tree.
+// If filter is malformed, the error is a *SyntaxError.
+func Parse(filter string, options Options) (Node, error) {
+	p := &parser{tokens: lexer.GetTokens(filter), options: options}
+	return p.parseFilter(filter)
 }
 
-// next reads the next token or eof
-func (p *parser) next() (token lexer.Token) {
-	if p.pos >= len(p.tokens) { // No more tokens
-		panic("We should never get here because the parser should know to stop after reading TokenEOF or TokenError") //return eof
+// parseFilter parses filter, whose tokens are p.tokens, stopping at the first error.
+func (p *parser) parseFilter(filter string) (Node, error) {
+	for _, t := range p.tokens {
+		if t.TokenKind == lexer.TokenError { // Report lexical errors before any syntax errors
+			return nil, &SyntaxError{Msg: t.Symbol, Position: t.Position, Filter: filter}
+		}
 	}
-	p.pos += 1
-	return p.tokens[p.pos-1]
+	n, err := p.parse()
+	if se, ok := err.(*SyntaxError); ok {
+		se.Filter = filter
+	}
+	return n, err
 }
 
-// backup places the previously read token back
-func (p *parser) backup() { p.pos-- }
+// ParseDiagnostics parses filter like Parse but, instead of stopping at the first error, it reports
+// every problem it finds sorted by position. It also reports warnings, such as a comparison that
+// doesn't involve any properties. The root is nil if any diagnostic is an error.
+func ParseDiagnostics(filter string, options Options) (Node, []Diagnostic) {
+	p := &parser{tokens: lexer.GetTokens(filter), options: options, recovering: true}
+	n, err := p.parse()
+	if err != nil {
+		p.report(err)
+	}
+	sort.SliceStable(p.diagnostics, func(i, j int) bool {
+		return p.diagnostics[i].Position.Offset < p.diagnostics[j].Position.Offset
+	})
+	for _, d := range p.diagnostics {
+		d.Filter = filter
+		if d.Severity == SeverityError {
+			n = nil
+		}
+	}
+	return n, p.diagnostics
+}
 
-func (p *parser) acceptOne(tokenKind lexer.TokenKind) bool {
-	if tokenKind == p.next().TokenKind {
-		return true
+func (p *parser) parse() (Node, error) {
+	n, err := p.parseOr()
+	for err == nil {
+		start := p.pos
+		switch t := p.next(); t.TokenKind {
+		case lexer.TokenEOF:
+			return n, requireBoolean(n)
+		case lexer.TokenRightParen:
+			err = errorf(t.Position, "Unbalanced parentheses")
+		default:
+			err = errorf(t.Position, "Expected 'and' or 'or' before: %s", describe(t))
+		}
+		if p.recovering { // Report the error and parse the rest of the filter to find more problems
+			p.recover(start, p.depth, err)
+			err = nil
+			if p.acceptKeyword(string(LogicalAnd)) || p.acceptKeyword(string(LogicalOr)) {
+				_, err = p.parseOr()
+			}
+		}
 	}
-	p.backup()
-	return false
+	return nil, err
+}
+
+// recover skips to the next and, or, or ')' at depth and reports err.
+func (p *parser) recover(start, depth int, err error) {
+	p.skipTo(depth, func(t lexer.Token) bool {
+		return t.TokenKind == lexer.TokenRightParen ||
+			t.TokenKind == lexer.TokenSymbol && (p.keyword(t) == string(LogicalAnd) || p.keyword(t) == string(LogicalOr))
+	})
+	p.reportSince(start, err)
 }
 
-func (p *parser) emit(kind NodeKind, c ...any) {
-	n := Node{NodeKind: kind}
-	switch kind { // If any type assertion panics, it's an error in this file.
-	case NodeComparison:
-		n.Comparison = c[0].(Comparison)
-	case NodeContains:
-		n.Contains = c[0].(Contains)
+// skipTo consumes tokens until EOF or, at depth, a token for which stop returns true.
+func (p *parser) skipTo(depth int, stop func(t lexer.Token) bool) {
+	for t := p.peek(); t.TokenKind != lexer.TokenEOF && !(p.depth <= depth && stop(t)); t = p.peek() {
+		p.next()
 	}
-	showNode(n)
-	p.nodes = append(p.nodes, n)
 }
 
-func (p *parser) emitError(format string, args ...any) []Node {
-	n := Node{NodeKind: NodeError, Error: errors.New(fmt.Sprintf(format, args...))}
-	showNode(n)
-	return append(p.nodes, n)
+// reportSince reports err. If the tokens consumed since start include lexical errors,
+// they're reported instead because they likely caused err.
+func (p *parser) reportSince(start int, err error) {
+	lexical := false
+	for _, t := range p.tokens[start:p.pos] {
+		if t.TokenKind == lexer.TokenError {
+			p.report(errorf(t.Position, "%s", t.Symbol))
+			lexical = true
+		}
+	}
+	if !lexical {
+		p.report(err)
+	}
 }
 
-// GetNodes returns the nodes of filter
-func GetNodes(filter string) []Node {
-	parens := 0
-	p := &parser{tokens: lexer.GetTokens(filter)}
-	for {
-		switch t := p.next(); t.TokenKind { // Consume next token
-		case lexer.TokenError:
-			return p.emitError(t.Symbol)
+// report records err, a *SyntaxError, as an error diagnostic.
+func (p *parser) report(err error) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{Severity: SeverityError, SyntaxError: err.(*SyntaxError)})
+}
 
-		case lexer.TokenEOF:
-			if parens > 0 {
-				return p.emitError("Unbalanced parentheses")
+// warnf records a warning diagnostic at pos.
+func (p *parser) warnf(pos lexer.Position, format string, args ...any) {
+	if p.recovering {
+		p.diagnostics = append(p.diagnostics, Diagnostic{Severity: SeverityWarning, SyntaxError: errorf(pos, format, args...).(*SyntaxError)})
+	}
+}
+
+func (p *parser) parseOr() (Node, error) {
+	return p.parseLogical(p.parseAnd, LogicalOr)
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	return p.parseLogical(p.parseNot, LogicalAnd)
+}
+
+// parseLogical parses a sequence of boolean operands (parsed by parseOperand) separated by op.
+func (p *parser) parseLogical(parseOperand func() (Node, error), op LogicalOp) (Node, error) {
+	start := p.pos
+	left, err := p.parseLogicalOperand(parseOperand)
+	for err == nil && p.acceptKeyword(string(op)) {
+		var right Node
+		if right, err = p.parseLogicalOperand(parseOperand); err == nil {
+			if err = requireBoolean(left, right); err != nil && p.recovering {
+				p.report(err)
+				err = nil
 			}
-			p.emit(NodeEOF)
-			return p.nodes
+			left = p.track(&Logical{Op: op, Left: left, Right: right}, start, p.pos)
+		}
+	}
+	return left, err
+}
 
-		case lexer.TokenLeftParen:
-			parens++
-			p.emit(NodeLeftParen)
+// parseLogicalOperand calls parseOperand. When recovering, it reports any error and returns a bad node instead.
+func (p *parser) parseLogicalOperand(parseOperand func() (Node, error)) (Node, error) {
+	start, depth := p.pos, p.depth
+	n, err := parseOperand()
+	if err == nil || !p.recovering {
+		return n, err
+	}
+	if p.depth < depth && p.tokens[p.pos-1].TokenKind == lexer.TokenRightParen {
+		p.pos, p.depth = p.pos-1, p.depth+1 // Leave the ')' that closes an enclosing group for that group
+	}
+	p.recover(start, depth, err)
+	return &bad{Position: p.tokens[start].Position}, nil
+}
 
-		case lexer.TokenRightParen:
-			parens--
-			p.emit(NodeRightParen)
-
-		case lexer.TokenSymbol: // and/or OR the string representing a function/property name
-			switch logicalOp := t.Symbol; logicalOp {
-			case "and":
-				p.emit(NodeAnd)
-
-			case "or":
-				p.emit(NodeOr)
-
-			default: // Property or function name
-				// if token after symbol is a left paren, this is a function name; else a proprty name
-				if p.acceptOne(lexer.TokenLeftParen) { // Function name
-					switch t.Symbol { // Do we recogize this function?
-					case "contains": // contains function
-						propName := p.next()
-						if !p.acceptOne(lexer.TokenComma) { // Is there a comma?
-							return p.emitError("Expected ',' after property name: %s", propName.Symbol)
-						}
-						literal := p.next()
-						if literal.TokenKind == lexer.TokenEOF {
-							return p.emitError("Expected literal after ','")
-						}
-						if !p.acceptOne(lexer.TokenRightParen) { // Is there a right paren?
-							return p.emitError("Expected ')' after literal: %s", literal.Symbol)
-						}
-						p.emit(NodeContains, Contains{propName.Symbol, literal})
-						continue
-
-					default: // Unrecognized function name
-						return p.emitError("Unrecognized function name: %s", t.Symbol)
+func (p *parser) parseNot() (Node, error) {
+	start, not := p.pos, p.peek()
+	if !p.acceptKeyword(keywordNot) {
+		return p.parseComparison()
+	}
+	operand, err := p.parseNot()
+	if err == nil {
+		err = requireBoolean(operand)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.track(&Not{Operand: operand, Position: not.Position}, start, p.pos), nil
+}
+
+func (p *parser) parseComparison() (Node, error) {
+	start := p.pos
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	if op.TokenKind == lexer.TokenSymbol && p.keyword(op) == keywordIn {
+		return p.parseIn(left, start)
+	}
+	if op.TokenKind != lexer.TokenSymbol || !isCompareOp(p.keyword(op)) {
+		switch {
+		case isBoolean(left): // A parenthesized expression, predicate function call, true, or false
+			return left, nil
+		case op.TokenKind == lexer.TokenRightParen: // A parenthesized operand: (price add tax) mul 2 gt 100
+			return left, nil
+		case op.TokenKind == lexer.TokenSymbol && !keywords[p.keyword(op)]:
+			return nil, errorf(op.Position, "Invalid comparison operator (%s)", op.Symbol)
+		default:
+			return nil, notBooleanError(left)
+		}
+	}
+	p.next()
+	if t := p.peek(); t.TokenKind == lexer.TokenEOF {
+		return nil, errorf(t.Position, "Expected expression after comparison operator: %s", op.Symbol)
+	}
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	c := &Comparison{Left: left, Op: CompareOp(p.keyword(op)), Right: right, Position: left.Pos()}
+	if isConstant(c.Left) && !isConstant(c.Right) { // Normalize: 5 lt count becomes count gt 5
+		c.Left, c.Op, c.Right = c.Right, c.Op.Reverse(), c.Left
+	}
+	if l, ok := c.Right.(*Literal); ok && l.Value == nil && c.Op != CompareEq && c.Op != CompareNe {
+		return nil, errorf(op.Position, "Only eq and ne can compare with null, found: %s", c.Op)
+	}
+	if err := p.checkKinds(c.Left, c.Right); err != nil {
+		return nil, err
+	}
+	if isConstant(c.Left) && isConstant(c.Right) {
+		p.warnf(c.Position, "Comparison doesn't involve any properties: %s", c)
+	}
+	return p.track(c, start, p.pos), nil
+}
+
+func (p *parser) parseIn(left Node, start int) (Node, error) {
+	in := p.next()
+	if !p.acceptOne(lexer.TokenLeftParen) {
+		return nil, errorf(p.peek().Position, "Expected '(' after in")
+	}
+	var list []*Literal
+	for !p.acceptOne(lexer.TokenRightParen) {
+		if len(list) > 0 && !p.acceptOne(lexer.TokenComma) {
+			return nil, errorf(p.peek().Position, "Expected ',' or ')' after list element: %s", list[len(list)-1])
+		}
+		l, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		if l.(*Literal).Value == nil {
+			return nil, errorf(l.Pos(), "List elements can't be null; use: %s eq null", left)
+		}
+		list = append(list, l.(*Literal))
+	}
+	if len(list) == 0 {
+		return nil, errorf(in.Position, "Expected at least one literal in the list after in")
+	}
+	n, err := newIn(left, list)
+	if err != nil {
+		return nil, err
+	}
+	kindOf := list[0]
+	for _, l := range list {
+		if isDecimalLiteral(l) { // A string in decimal form may be in a list of numbers that includes a decimal
+			kindOf = l
+			break
+		}
+	}
+	return p.track(n, start, p.pos), p.checkKinds(left, kindOf)
+}
+
+func (p *parser) parseAdditive() (Node, error) {
+	return p.parseArithmetic(p.parseMultiplicative, ArithmeticAdd, ArithmeticSub)
+}
+
+func (p *parser) parseMultiplicative() (Node, error) {
+	return p.parseArithmetic(p.parsePrimary, ArithmeticMul, ArithmeticDiv, ArithmeticDivBy, ArithmeticMod)
+}
+
+// parseArithmetic parses a left-associative sequence of operands (parsed by parseOperand) separated by any of ops.
+func (p *parser) parseArithmetic(parseOperand func() (Node, error), ops ...ArithmeticOp) (Node, error) {
+	start := p.pos
+	left, err := parseOperand()
+	for err == nil {
+		op, ok := p.acceptArithmeticOp(ops)
+		if !ok {
+			break
+		}
+		var right Node
+		if right, err = parseOperand(); err == nil {
+			lk, rk := p.staticKind(left), p.staticKind(right)
+			if _, ok := op.resultKind(lk, rk); !ok {
+				for _, operand := range []Node{left, right} {
+					if k := p.staticKind(operand); k != "" && k != KindNull && k != KindNumber && k != KindTime && k != KindDuration {
+						return nil, errorf(operand.Pos(), "Arithmetic operator %s requires numeric operands, found: %s", op, operand)
 					}
 				}
+				return nil, errorf(left.Pos(), "Arithmetic operator %s can't be applied to a %s and a %s", op, lk, rk)
+			}
+			left = p.track(&Arithmetic{Op: op, Left: left, Right: right}, start, p.pos)
+		}
+	}
+	return left, err
+}
 
-				// Not a function; we assume a property name for a logical comparison operation
-				propName := t.Symbol // Not a function; this is a property name
-				op := p.next()
-				if op.TokenKind != lexer.TokenSymbol {
-					return p.emitError("Expected comparison operator after property name: %s", propName)
-				}
-				isCompareOp := func(s string) bool {
-					n := strings.Index("eqneleltgegt", s)
-					return (n >= 0) && ((n % 2) == 0)
-				}
-				if !isCompareOp(op.Symbol) {
-					return p.emitError("Invalid comparison operator (%s)", op)
-				}
-				literal := p.next()
-				if literal.TokenKind == lexer.TokenEOF {
-					return p.emitError("Expected literal after comparison operator: %s", op)
-				}
-				p.emit(NodeComparison, Comparison{
-					PropName: propName,
-					Op:       CompareOp(op.Symbol),
-					Literal:  literal})
+// acceptArithmeticOp consumes the next token if it is one of ops
+func (p *parser) acceptArithmeticOp(ops []ArithmeticOp) (ArithmeticOp, bool) {
+	for _, op := range ops {
+		if p.acceptKeyword(string(op)) {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	if t := p.peek(); p.acceptOne(lexer.TokenLeftParen) {
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return n, p.closeParen(t)
+	}
+	if t := p.peek(); t.TokenKind == lexer.TokenSymbol && p.lookahead(1).TokenKind == lexer.TokenLeftParen {
+		return p.parseCall(p.next())
+	}
+	if p.isLiteral(p.peek()) {
+		return p.parseLiteral()
+	}
+	return p.parseProperty()
+}
+
+func (p *parser) parseCall(name lexer.Token) (Node, error) {
+	f, ok := functions[p.keyword(name)]
+	if !ok {
+		return nil, errorf(name.Position, "Unrecognized function name: %s", name.Symbol)
+	}
+	start := p.pos - 1 // The name
+	p.next()           // Consume the '('
+	c := &Call{Name: p.keyword(name), Position: name.Position}
+	for !p.acceptOne(lexer.TokenRightParen) {
+		if len(c.Args) > 0 && !p.acceptOne(lexer.TokenComma) {
+			return nil, errorf(p.peek().Position, "Expected ',' or ')' after argument: %s", c.Args[len(c.Args)-1])
+		}
+		if t := p.peek(); t.TokenKind == lexer.TokenEOF {
+			return nil, errorf(t.Position, "Expected ')' after %s arguments", name.Symbol)
+		}
+		arg, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		c.Args = append(c.Args, arg)
+	}
+	if n := len(c.Args); n < f.minArgs || n > f.maxArgs {
+		if f.minArgs == f.maxArgs {
+			return nil, errorf(c.Position, "Function %s requires %d arguments; found %d", c.Name, f.minArgs, n)
+		}
+		return nil, errorf(c.Position, "Function %s requires %d to %d arguments; found %d", c.Name, f.minArgs, f.maxArgs, n)
+	}
+	if f.existence && !isProperty(c.Args[0]) {
+		return nil, errorf(c.Args[0].Pos(), "Function %s requires a property argument, found: %s", c.Name, c.Args[0])
+	}
+	return p.track(c, start, p.pos), nil
+}
+
+const (
+	keywordNot = "not"
+	keywordIn  = "in"
+)
+
+// keywords can't be used as property names
+var keywords = map[string]bool{string(LogicalAnd): true, string(LogicalOr): true, keywordNot: true}
+
+func (p *parser) parseProperty() (Node, error) {
+	start := p.pos
+	t := p.next()
+	if t.TokenKind == lexer.TokenPointer {
+		return p.parsePointer(t, start)
+	}
+	name, ok := p.propertyName(t)
+	if !ok {
+		return nil, errorf(t.Position, "Expected property name, found: %s", describe(t))
+	}
+	prop := &Property{Path: []string{name}, Position: t.Position, separator: p.separator()}
+	if p.isVariable(name) {
+		if t.TokenKind == lexer.TokenSymbol {
+			prop.Variable, prop.Path = name, nil
+		} else {
+			prop.shadowed = true // A quoted name is never a range variable
+		}
+	}
+	for {
+		end, sep := p.pos, p.peek()
+		if p.acceptOne(lexer.TokenLeftBracket) {
+			t := p.next()
+			if !isIndex(t) {
+				return nil, errorf(t.Position, "Expected array index after '[', found: %s", describe(t))
+			}
+			if !p.acceptOne(lexer.TokenRightBracket) {
+				return nil, errorf(p.peek().Position, "Expected ']' after array index: %s", t.Symbol)
 			}
+			prop.Path = append(prop.Path, t.Symbol)
+			continue
+		}
+		if !p.acceptOne(lexer.TokenSlash) && !p.acceptOne(lexer.TokenDot) {
+			return p.track(prop, start, end), nil
+		}
+		t := p.next()
+		if lambdaOp := LambdaOp(p.keyword(t)); sep.TokenKind == lexer.TokenSlash && t.TokenKind == lexer.TokenSymbol &&
+			(lambdaOp == LambdaAny || lambdaOp == LambdaAll) && p.peek().TokenKind == lexer.TokenLeftParen {
+			p.track(prop, start, end)
+			return p.parseLambda(prop, lambdaOp, start)
+		}
+		if only := p.separator(); only != "" && sep.Symbol != only {
+			return nil, errorf(sep.Position, "Path separator '%s' isn't allowed; use '%s'", sep.Symbol, only)
+		}
+		name, ok := p.propertyName(t)
+		if isIndex(t) {
+			name, ok = t.Symbol, true
+		}
+		if !ok {
+			return nil, errorf(t.Position, "Expected property name after '%s', found: %s", sep.Symbol, describe(t))
+		}
+		prop.Path = append(prop.Path, name)
+	}
+}
+
+// separator returns the only path separator the PathSeparators option allows; "" if both are allowed.
+func (p *parser) separator() string {
+	switch p.options.PathSeparators {
+	case SlashOnly:
+		return "/"
+	case PeriodOnly:
+		return "."
+	}
+	return ""
+}
+
+// isIndex returns true if t is an array index in a property path: an integer without a suffix
+func isIndex(t lexer.Token) bool {
+	return t.TokenKind == lexer.TokenNumber && t.Number == lexer.NumberInteger && t.Symbol == t.Value
+}
+
+// ArrayIndex returns the array index that a property path's name represents: a decimal integer without
+// leading zeros or a leading +. A negative index counts back from the end of the array: -1 is the last element.
+func ArrayIndex(name string) (int, bool) {
+	i, err := strconv.Atoi(name)
+	return i, err == nil && strconv.Itoa(i) == name
+}
+
+// propertyName returns the property name in t, a symbol that isn't a keyword or literal, or a quoted name.
+func (p *parser) propertyName(t lexer.Token) (string, bool) {
+	switch {
+	case t.TokenKind == lexer.TokenQuotedName:
+		return t.Value, true
+	case t.TokenKind != lexer.TokenSymbol || keywords[p.keyword(t)] || p.isLiteral(t):
+		return "", false
+	}
+	return t.Symbol, true
+}
+
+// pointerEscapes decodes a JSON Pointer's reference tokens: ~1 is a '/' and ~0 is a '~'
+var pointerEscapes = strings.NewReplacer("~1", "/", "~0", "~")
+
+// invalidEscapeRegexp matches a '~' in a JSON Pointer that isn't part of an escape
+var invalidEscapeRegexp = regexp.MustCompile(`~([^01]|$)`)
+
+// parsePointer parses a JSON Pointer (RFC 6901), which is always relative to the filtered object.
+// Its last reference token may be a lambda operator: /orders/any(o: o/total gt 100)
+func (p *parser) parsePointer(t lexer.Token, start int) (Node, error) {
+	prop := &Property{Path: strings.Split(t.Symbol[1:], "/"), Position: t.Position, separator: p.separator()}
+	if invalidEscapeRegexp.MatchString(t.Symbol) {
+		return nil, errorf(t.Position, "Invalid JSON Pointer escape ('~' must be followed by 0 or 1): %s", t.Symbol)
+	}
+	for i, name := range prop.Path {
+		prop.Path[i] = pointerEscapes.Replace(name)
+	}
+	prop.shadowed = p.isVariable(prop.Path[0])
+	if n := len(prop.Path) - 1; n > 0 && p.peek().TokenKind == lexer.TokenLeftParen {
+		if lambdaOp := LambdaOp(prop.Path[n]); lambdaOp == LambdaAny || lambdaOp == LambdaAll {
+			prop.Path = prop.Path[:n]
+			p.track(prop, start, p.pos)
+			return p.parseLambda(prop, lambdaOp, start)
+		}
+	}
+	return p.track(prop, start, p.pos), nil
+}
+
+// isVariable returns true if name is the range variable of an enclosing lambda.
+func (p *parser) isVariable(name string) bool {
+	for _, v := range p.variables {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLambda parses the lambda applied to collection, which starts at token start.
+func (p *parser) parseLambda(collection *Property, op LambdaOp, start int) (Node, error) {
+	open := p.next()
+	l := &Lambda{Collection: collection, Op: op}
+	if t := p.peek(); p.acceptOne(lexer.TokenRightParen) {
+		if op != LambdaAny {
+			return nil, errorf(t.Position, "Expected range variable after %s/%s(", collection, op)
+		}
+		return p.track(l, start, p.pos), nil // any() is true if the collection isn't empty
+	}
+	v := p.next()
+	if v.TokenKind != lexer.TokenSymbol || keywords[p.keyword(v)] || p.isLiteral(v) {
+		return nil, errorf(v.Position, "Expected range variable after %s/%s(, found: %s", collection, op, describe(v))
+	}
+	if !p.acceptOne(lexer.TokenColon) {
+		return nil, errorf(p.peek().Position, "Expected ':' after range variable: %s", v.Symbol)
+	}
+	l.Variable = v.Symbol
+	p.variables = append(p.variables, l.Variable) // The variable is in scope only within the predicate
+	predicate, err := p.parseOr()
+	p.variables = p.variables[:len(p.variables)-1]
+	if err == nil {
+		err = requireBoolean(predicate)
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.Predicate = predicate
+	err = p.closeParen(open)
+	return p.track(l, start, p.pos), err
+}
+
+// closeParen consumes the ')' matching open. When recovering, any tokens before the ')' are reported and skipped.
+func (p *parser) closeParen(open lexer.Token) error {
+	if p.acceptOne(lexer.TokenRightParen) {
+		return nil
+	}
+	t, start := p.peek(), p.pos
+	if t.TokenKind == lexer.TokenEOF {
+		return errorf(open.Position, "Unbalanced parentheses")
+	}
+	if !p.recovering {
+		return errorf(t.Position, "Expected 'and', 'or', or ')' before: %s", describe(t))
+	}
+	p.skipTo(p.depth, func(t lexer.Token) bool { return t.TokenKind == lexer.TokenRightParen })
+	if p.peek().TokenKind == lexer.TokenEOF {
+		return errorf(open.Position, "Unbalanced parentheses")
+	}
+	p.reportSince(start, errorf(t.Position, "Expected 'and', 'or', or ')' before: %s", describe(t)))
+	p.next() // Consume the ')'
+	return nil
+}
+
+// isBoolean returns true if n is known to produce a boolean.
+func isBoolean(n Node) bool {
+	switch n := n.(type) {
+	case *Logical, *Not, *Comparison, *In, *Lambda, *bad:
+		return true
+	case *Call:
+		return functions[n.Name].result == KindBoolean
+	case *Literal:
+		_, ok := n.Value.(bool)
+		return ok
+	}
+	return false
+}
+
+// requireBoolean returns an error if any of nodes isn't known to produce a boolean.
+func requireBoolean(nodes ...Node) error {
+	for _, n := range nodes {
+		if !isBoolean(n) {
+			return notBooleanError(n)
+		}
+	}
+	return nil
+}
+
+// notBooleanError returns the error for n appearing where a boolean is required.
+func notBooleanError(n Node) error {
+	if isProperty(n) {
+		return errorf(n.Pos(), "Expected comparison operator after property name: %s", n)
+	}
+	return errorf(n.Pos(), "Expected comparison operator after: %s", n)
+}
+
+// isProperty returns true if n is a property reference.
+func isProperty(n Node) bool {
+	_, ok := n.(*Property)
+	return ok
+}
+
+// isConstant returns true if n produces the same value for every evaluation: it
+// doesn't reference a property or call a volatile function such as now().
+func isConstant(n Node) (constant bool) {
+	constant = true
+	Inspect(n, func(n Node) bool {
+		if c, ok := n.(*Call); ok && functions[c.Name].volatile {
+			constant = false
+		}
+		constant = constant && !isProperty(n)
+		return constant
+	})
+	return constant
+}
+
+// isLiteral returns true if t is a literal: null, true, false, a number, a date, a time of day, a GUID,
+// 'string', or a string prefixed with a type name such as time'rfc3339'
+func (p *parser) isLiteral(t lexer.Token) bool {
+	switch {
+	case t.TokenKind == lexer.TokenNumber, t.TokenKind == lexer.TokenDate:
+		return true
+	case t.TokenKind == lexer.TokenTimeOfDay, t.TokenKind == lexer.TokenGUID:
+		return true
+	case t.TokenKind == lexer.TokenString, t.TokenKind == lexer.TokenTypedString:
+		return true
+	case t.TokenKind != lexer.TokenSymbol:
+		return false
+	}
+	k := p.keyword(t)
+	return k == "null" || k == "true" || k == "false"
+}
+
+func (p *parser) parseLiteral() (Node, error) {
+	start := p.pos
+	t := p.next()
+	if !p.isLiteral(t) {
+		return nil, errorf(t.Position, "Expected literal, found: %s", describe(t))
+	}
+	t.Symbol = p.keyword(t) // Lowercase null, true, false, or a type name if case-insensitive
+	v, err := literalValue(t)
+	if err != nil {
+		return nil, errorf(t.Position, "%s", err)
+	}
+	return p.track(&Literal{Token: t, Value: v}, start, p.pos), nil
+}
+
+// literalValue converts literal token t to its Go value.
+func literalValue(t lexer.Token) (any, error) {
+	if t.TokenKind == lexer.TokenNumber {
+		if t.Number == lexer.NumberInteger {
+			return parseNumber(t.Value, parseInteger)
+		}
+		if t.Number == lexer.NumberDecimal {
+			return ParseDecimal(t.Value)
+		}
+		return parseNumber(t.Value, func(s string) (any, error) { return strconv.ParseFloat(s, 64) })
+	}
+	if t.TokenKind == lexer.TokenDate {
+		return parseDate(t.Symbol)
+	}
+	if t.TokenKind == lexer.TokenTimeOfDay {
+		return parseTimeOfDay(t.Symbol)
+	}
+	if t.TokenKind == lexer.TokenGUID {
+		return parseGUID(t.Symbol)
+	}
+	if t.TokenKind == lexer.TokenString {
+		return t.Value, nil
+	}
+	if t.TokenKind == lexer.TokenTypedString {
+		return typedStringValue(t)
+	}
+	switch t.Symbol {
+	case "null":
+		return nil, nil
+	case "true", "false":
+		return t.Symbol == "true", nil
+	}
+	return nil, fmt.Errorf("Literal has improper syntax: '%s'", t.Symbol)
+}
+
+// typedStringValue converts t, a string prefixed with a type name, to its Go value.
+func typedStringValue(t lexer.Token) (any, error) {
+	switch typeName := t.Symbol[:strings.IndexByte(t.Symbol, '\'')]; typeName {
+	case "time":
+		v, err := time.Parse(time.RFC3339, t.Value)
+		if err != nil {
+			return nil, fmt.Errorf("Time has improper syntax: %s", t.Symbol)
+		}
+		return v, nil
+	case "duration":
+		return parseDuration(t.Value)
+	case "guid":
+		return parseGUID(t.Value)
+	case "binary":
+		return parseBinary(t.Value)
+	default:
+		return nil, fmt.Errorf("Unrecognized literal type '%s': %s", typeName, t.Symbol)
+	}
+}
+
+// parseBinary decodes s, base64 with either the standard or URL-safe alphabet and optional padding.
+func parseBinary(s string) ([]byte, error) {
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if b, err := encoding.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("Binary has improper syntax: '%s'", s)
+}
+
+// parseInteger parses s as an int64 or, if it is out of int64's range, a *big.Int.
+func parseInteger(s string) (any, error) {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if numerr, ok := err.(*strconv.NumError); ok && numerr.Err == strconv.ErrRange {
+		if b, ok := new(big.Int).SetString(strings.TrimPrefix(s, "+"), 10); ok {
+			return b, nil
+		}
+	}
+	return i, err
+}
+
+// parseNumber calls parse on s mapping any strconv.NumError to a descriptive error.
+func parseNumber(s string, parse func(s string) (any, error)) (any, error) {
+	v, err := parse(s)
+	if numerr, ok := err.(*strconv.NumError); ok {
+		switch numerr.Err {
+		case strconv.ErrRange:
+			return nil, fmt.Errorf("Number out of range: '%s'", s)
+		case strconv.ErrSyntax:
+			return nil, fmt.Errorf("Number has improper syntax: '%s'", s)
 		}
 	}
-	//panic("lexer didn't return EOF or Error") // We should never get here
+	return v, err
 }
@@ -0,0 +1,421 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseTest is a filter and the text its expression tree prints as; err is a substring of the expected
+// error message if parsing must fail.
+type parseTest struct {
+	filter string
+	want   string
+	err    string
+}
+
+// testParse parses each test's filter with options, checks its printed form, and checks that the printed
+// form parses to an expression tree that prints the same way.
+func testParse(t *testing.T, tests []parseTest, options Options) {
+	t.Helper()
+	for _, tt := range tests {
+		n, err := Parse(tt.filter, options)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Parse(%q): err = %v, want an error containing %q", tt.filter, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.filter, err)
+			continue
+		}
+		if got := n.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.filter, got, tt.want)
+		}
+		if n2, err := Parse(n.String(), options); err != nil || n2.String() != n.String() {
+			t.Errorf("Parse(%q) doesn't round-trip: %v, %v", n.String(), n2, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	testParse(t, []parseTest{
+		{filter: "name eq 'Jeff'", want: "name eq 'Jeff'"},
+		{filter: "age gt 30 and age le 65", want: "age gt 30 and age le 65"},
+		{filter: "a eq 1 or b eq 2 and c eq 3", want: "a eq 1 or b eq 2 and c eq 3"},
+		{filter: "(a eq 1 or b eq 2) and c eq 3", want: "(a eq 1 or b eq 2) and c eq 3"},
+		{filter: "((a eq 1))", want: "a eq 1"},
+		{filter: "child.grandchild ne null", want: "child.grandchild ne null"},
+		{filter: "flag eq true", want: "flag eq true"},
+		{filter: "contains(name, 'ef')", want: "contains(name, 'ef')"},
+		{filter: "true", want: "true"},
+		{filter: "name", err: "Expected"},
+		{filter: "name xx 5", err: "xx"},
+		{filter: "(a eq 1", err: "Unbalanced parentheses"},
+		{filter: "a eq 1)", err: ")"},
+		{filter: "a eq", err: "Expected"},
+		{filter: "foo(a) eq 1", err: "foo"},
+	}, Options{})
+}
+
+func TestParseTree(t *testing.T) {
+	n, err := Parse("a eq 1 and b lt 2.5", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, ok := n.(*Logical)
+	if !ok || l.Op != LogicalAnd {
+		t.Fatalf("root = %#v, want an and", n)
+	}
+	c, ok := l.Left.(*Comparison)
+	if !ok || c.Op != CompareEq || c.Left.String() != "a" || c.Right.(*Literal).Value != int64(1) {
+		t.Errorf("left = %#v, want a eq 1", l.Left)
+	}
+	if c, ok := l.Right.(*Comparison); !ok || c.Op != CompareLt {
+		t.Errorf("right = %#v, want b lt 2.5", l.Right)
+	}
+}
+
+func TestParseNot(t *testing.T) {
+	testParse(t, []parseTest{
+		{filter: "not a eq 1", want: "not (a eq 1)"},
+		{filter: "not a eq 1 and b eq 2", want: "not (a eq 1) and b eq 2"},
+		{filter: "not (a eq 1 and b eq 2)", want: "not (a eq 1 and b eq 2)"},
+		{filter: "not not contains(s, 'x')", want: "not not contains(s, 'x')"},
+		{filter: "not", err: "Expected"},
+		{filter: "not eq 2", err: "Expected"},
+	}, Options{})
+}
+
+func TestParseArithmetic(t *testing.T) {
+	testParse(t, []parseTest{
+		{filter: "a add 1 mul 2 eq 25", want: "a add 1 mul 2 eq 25"},
+		{filter: "(a add 1) mul 2 eq 48", want: "(a add 1) mul 2 eq 48"},
+		{filter: "a sub 3 sub 10 eq 10", want: "a sub 3 sub 10 eq 10"},
+		{filter: "a sub (3 sub 10) eq 10", want: "a sub (3 sub 10) eq 10"},
+		{filter: "a div 2 eq b divby 4 mod 3", want: "a div 2 eq b divby 4 mod 3"},
+		{filter: "(a add 1)", err: "Expected comparison operator"},
+		{filter: "a add (a eq 1) eq 2", err: "a eq 1"},
+	}, Options{})
+}
+
+func TestParseIn(t *testing.T) {
+	testParse(t, []parseTest{
+		{filter: "status in ('open', 'closed')", want: "status in ('open', 'closed')"},
+		{filter: "n in (1,2 , 3)", want: "n in (1, 2, 3)"},
+		{filter: "n add 1 in (24) and b eq true", want: "n add 1 in (24) and b eq true"},
+		{filter: "s in ('a', 1)", err: "same type"},
+		{filter: "s in ()", err: "at least one literal"},
+		{filter: "s in (null)", err: "null"},
+		{filter: "s in (t)", err: "Expected literal"},
+	}, Options{})
+}
+
+func TestParseLambda(t *testing.T) {
+	testParse(t, []parseTest{
+		{filter: "tags/any(t: t eq 'urgent')", want: "tags/any(t: t eq 'urgent')"},
+		{filter: "items/all(i:i/price lt 100)", want: "items/all(i: i/price lt 100)"},
+		{filter: "orders/any(o: o/items/any(i: i/qty gt 5 and o/id eq 1))", want: "orders/any(o: o/items/any(i: i/qty gt 5 and o/id eq 1))"},
+		{filter: "tags/any()", want: "tags/any()"},
+		{filter: "not tags/any(t: t eq 1)", want: "not tags/any(t: t eq 1)"},
+		{filter: "tags/all()", err: "all"},
+		{filter: "tags/any(t t eq 1)", err: "':'"},
+		{filter: "tags/any(t: t add 1)", err: "Expected comparison operator"},
+	}, Options{})
+}
+
+func TestParseLiteralOnLeft(t *testing.T) {
+	testParse(t, []parseTest{
+		{filter: "5 lt count", want: "count gt 5"},
+		{filter: "'admin' eq role", want: "role eq 'admin'"},
+		{filter: "null ne x", want: "x ne null"},
+		{filter: "1 eq 1", want: "1 eq 1"},
+		{filter: "2 mul 10 le n add 1", want: "n add 1 ge 2 mul 10"},
+		{filter: "'Jeff' in ('Jeff')", want: "'Jeff' in ('Jeff')"},
+		{filter: "null lt x", err: "null"},
+	}, Options{})
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	for _, tt := range []struct {
+		filter       string
+		msg          string
+		line, column int
+		caret        string
+	}{
+		{"name eq 'Jeff' xx 5", "xx", 1, 16, "name eq 'Jeff' xx 5\n               ^"},
+		{"city eq 'São' and\n\tint eq", "Expected", 2, 8, "\tint eq\n\t      ^"},
+		{"int eq 1 and\r\n  city eq 'São Paulo' xx", "xx", 2, 23, "  city eq 'São Paulo' xx\n                      ^"},
+		{"int eq 1 and ! eq 2", "Invalid character: !", 1, 14, "int eq 1 and ! eq 2\n             ^"},
+		{"int in (1, 'a')", "same type", 1, 12, "int in (1, 'a')\n           ^"},
+		{"(a eq 1 xx)", "Expected 'and', 'or', or ')' before: xx", 1, 9, "(a eq 1 xx)\n        ^"},
+		{"a eq 1 and child.", "found: end of filter", 1, 18, "a eq 1 and child.\n                 ^"},
+		{"items[", "found: end of filter", 1, 7, "items[\n      ^"},
+		{"tags/any(", "found: end of filter", 1, 10, "tags/any(\n         ^"},
+	} {
+		_, err := Parse(tt.filter, Options{})
+		se, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("Parse(%q): err = %v, want a *SyntaxError", tt.filter, err)
+			continue
+		}
+		if !strings.Contains(se.Msg, tt.msg) || se.Position.Line != tt.line || se.Position.Column != tt.column {
+			t.Errorf("Parse(%q): err = %v, want %q at %d:%d", tt.filter, err, tt.msg, tt.line, tt.column)
+		}
+		if caret := se.Caret(); caret != tt.caret {
+			t.Errorf("Parse(%q).Caret() = %q, want %q", tt.filter, caret, tt.caret)
+		}
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	n, err := Parse("a eq 1 and\n  b/any(x: x eq 'é') or not c eq 2", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	Inspect(n, func(n Node) bool {
+		got = append(got, n.String()+"@"+n.Pos().String())
+		return true
+	})
+	want := "a eq 1 and b/any(x: x eq 'é') or not (c eq 2)@1:1 a eq 1 and b/any(x: x eq 'é')@1:1 a eq 1@1:1 a@1:1 1@1:6 " +
+		"b/any(x: x eq 'é')@2:3 b@2:3 x eq 'é'@2:12 x@2:12 'é'@2:17 not (c eq 2)@2:25 c eq 2@2:29 c@2:29 2@2:34"
+	if strings.Join(got, " ") != want {
+		t.Errorf("positions = %q, want %q", strings.Join(got, " "), want)
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	for _, tt := range []struct {
+		filter string
+		want   []string // Each diagnostic's severity, position, and message
+	}{
+		{"a eq 1", nil},
+		{"int eq 1 and xx and string eq 'a' or 1 eq 2", []string{
+			"error 1:14 Expected comparison operator after property name: xx",
+			"warning 1:38 Comparison doesn't involve any properties: 1 eq 2"}},
+		{"(a eq 1 xx) and b eq 2", []string{"error 1:9 Expected 'and', 'or', or ')' before: xx"}},
+		{"contains(a eq, 'x') and b eq 1 and c gt", []string{
+			"error 1:12 Expected ',' or ')' after argument: a",
+			"error 1:40 Expected expression after comparison operator: gt"}},
+		{"int eq 1 and ! eq 2 or # eq 3", []string{"error 1:14 Invalid character: !", "error 1:24 Invalid character: #"}},
+		{"a eq 1) and b eq 2", []string{"error 1:7 Unbalanced parentheses"}},
+		{"(a eq 1 and (b eq)) or c eq 1", []string{"error 1:18 Expected property name, found: )"}},
+		{"tags/any(t: t eq 1 zz) and foo(1) eq 2", []string{
+			"error 1:20 Expected 'and', 'or', or ')' before: zz",
+			"error 1:28 Unrecognized function name: foo"}},
+		{"int eq 1 and not", []string{"error 1:17 Expected property name, found: end of filter"}},
+		{"1 eq 1", []string{"warning 1:1 Comparison doesn't involve any properties: 1 eq 1"}},
+	} {
+		n, diagnostics := ParseDiagnostics(tt.filter, Options{})
+		var got []string
+		for _, d := range diagnostics {
+			got = append(got, fmt.Sprintf("%s %v %s", d.Severity, d.Position, d.Msg))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("ParseDiagnostics(%q) =\n%s\nwant\n%s", tt.filter, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+		hasError := strings.Contains(strings.Join(tt.want, "\n"), "error")
+		if (n == nil) != hasError {
+			t.Errorf("ParseDiagnostics(%q) root = %v, want nil: %v", tt.filter, n, hasError)
+		}
+		if _, err := Parse(tt.filter, Options{}); (err != nil) != hasError {
+			t.Errorf("Parse(%q): err = %v, want error: %v", tt.filter, err, hasError)
+		}
+	}
+}
+
+func TestParseTypedLiterals(t *testing.T) {
+	testParse(t, []parseTest{
+		{filter: "id eq guid'0123ABCD-89ab-cdef-0123-456789abcdef'", want: "id eq guid'0123ABCD-89ab-cdef-0123-456789abcdef'"},
+		{filter: "d eq duration'P1DT2H30M'", want: "d eq duration'P1DT2H30M'"},
+		{filter: "d eq 2024-05-01 and t eq 13:45", want: "d eq 2024-05-01 and t eq 13:45"},
+		{filter: "b eq binary'aGVsbG8='", want: "b eq binary'aGVsbG8='"},
+		{filter: "id eq guid'xyz'", err: "GUID"},
+		{filter: "d eq duration'P1Y'", err: "Duration"},
+		{filter: "d eq 2024-02-30", err: "Date"},
+		{filter: "t eq 24:00", err: "Time"},
+		{filter: "b eq binary'a'", err: "Binary"},
+		{filter: "t add duration'PT1H' eq t", want: "t add duration'PT1H' eq t"},
+		{filter: "t eq now() sub duration'PT1H' sub duration'PT1M'", want: "t eq now() sub duration'PT1H' sub duration'PT1M'"},
+		{filter: "t eq duration'PT1H' sub now()", err: "can't be applied to a duration and a time"},
+		{filter: "t add 'x' eq t", err: "numeric operands"},
+	}, Options{})
+}
+
+func TestParseCaseInsensitive(t *testing.T) {
+	testParse(t, []parseTest{
+		{filter: "Name EQ 'x' AND Active eq TRUE", want: "Name eq 'x' and Active eq true"},
+		{filter: "NOT (a Eq NULL Or b LT 1 ADD 2)", want: "not (a eq null or b lt 1 add 2)"},
+		{filter: "CONTAINS(Name, 'X') and tags/ANY(t: t IN ('A'))", want: "contains(Name, 'X') and tags/any(t: t in ('A'))"},
+		{filter: "At lt Time'2024-05-01T00:00:00Z' and Id eq GUID'0123ABCD-89ab-cdef-0123-456789abcdef'",
+			want: "At lt time'2024-05-01T00:00:00Z' and Id eq guid'0123ABCD-89ab-cdef-0123-456789abcdef'"},
+		{filter: "b eq BINARY'aGVsbG8='", want: "b eq binary'aGVsbG8='"},
+		{filter: "a eq Nope'x'", err: "nope"},
+	}, Options{CaseInsensitive: true})
+	testParse(t, []parseTest{
+		{filter: "a EQ 1", err: "EQ"},
+		{filter: "a lt Time'2024-05-01T00:00:00Z'", err: "Time"},
+	}, Options{})
+}
+
+func TestParseWhitespaceAndComments(t *testing.T) {
+	testParse(t, []parseTest{
+		{filter: "name eq 'Jeff'\r\n\tand\u00a0age gt 30", want: "name eq 'Jeff' and age gt 30"},
+		{filter: "-- Only adults\nage ge 18 // inclusive\n and /* not */ active eq true", want: "age ge 18 and active eq true"},
+		{filter: "a eq 'x -- y' and b eq '/* z */'", want: "a eq 'x -- y' and b eq '/* z */'"},
+		{filter: "a eq 1 /* unterminated", err: "Unterminated comment"},
+		{filter: "// nothing but a comment", err: "Expected"},
+	}, Options{})
+}
+
+func TestParseQuotedNamesAndPointers(t *testing.T) {
+	testParse(t, []parseTest{
+		{filter: `"odd.key".child eq 7`, want: `"odd.key".child eq 7`},
+		{filter: `"odd.key"/child eq 7`, want: `"odd.key".child eq 7`},
+		{filter: `"say ""hi""" eq 3 and "and" eq 4 and "plain" eq 5`, want: `"say ""hi""" eq 3 and "and" eq 4 and plain eq 5`},
+		{filter: `/k8s.io~1name eq 'web' and /a~0b eq 1`, want: `"k8s.io/name" eq 'web' and "a~b" eq 1`},
+		{filter: `/items/any(i: i/price gt 40)`, want: `items/any(i: i/price gt 40)`},
+		{filter: `tags/any(x: /x eq 1)`, want: `tags/any(x: /x eq 1)`},
+		{filter: `tags/any(x: "x" eq 1 and x eq 2)`, want: `tags/any(x: /x eq 1 and x eq 2)`},
+		{filter: `tags/any(x: /x/k8s.io~1name eq 1)`, want: `tags/any(x: /x/k8s.io~1name eq 1)`},
+		{filter: `tags/any(x: "x"."a b" eq 1)`, want: `tags/any(x: "x"."a b" eq 1)`},
+		{filter: `tags/any(x: /x/any(y: y eq x))`, want: `tags/any(x: /x/any(y: y eq x))`},
+		{filter: `a/any(x: x/any(y: /x eq 1 and /y eq 2))`, want: `a/any(x: x/any(y: /x eq 1 and /y eq 2))`},
+		{filter: `/a~2b eq 1`, err: "Invalid JSON Pointer escape"},
+		{filter: `/a~ eq 1`, err: "Invalid JSON Pointer escape"},
+		{filter: `"unterminated eq 3`, err: "Unterminated"},
+	}, Options{})
+}
+
+func TestParsePathSeparatorsAndIndexes(t *testing.T) {
+	testParse(t, []parseTest{
+		{filter: "address/city eq 'x' and address.city eq 'x'", want: "address.city eq 'x' and address.city eq 'x'"},
+		{filter: "items/0/name eq 'a' and items[0].name eq 'a'", want: "items[0].name eq 'a' and items[0].name eq 'a'"},
+		{filter: "items[-1]/tags/any(t: t eq 'x')", want: "items[-1].tags/any(t: t eq 'x')"},
+		{filter: "items/any(i: i[0] eq 1 and i/a.b eq 2)", want: "items/any(i: i[0] eq 1 and i/a.b eq 2)"},
+		{filter: "items.-2 eq 1", want: "items[-2] eq 1"},
+		{filter: "child..a eq 1", err: "Invalid character: ."},
+		{filter: "items[x] eq 1", err: "Expected array index"},
+		{filter: "items[1.5] eq 1", err: "Expected ']'"},
+		{filter: "items[0 eq 1", err: "Expected ']'"},
+	}, Options{})
+	testParse(t, []parseTest{
+		{filter: "address/city eq 'x' and items[0]/name eq 'a'", want: "address/city eq 'x' and items[0]/name eq 'a'"},
+		{filter: "tags/any(t: t/a eq 1) and /a.b/c eq 1", want: "tags/any(t: t/a eq 1) and \"a.b\"/c eq 1"},
+		{filter: "address.city eq 'x'", err: "Path separator '.' isn't allowed; use '/'"},
+	}, Options{PathSeparators: SlashOnly})
+	testParse(t, []parseTest{
+		{filter: "address.city eq 'x' and items[0].name eq 'a'", want: "address.city eq 'x' and items[0].name eq 'a'"},
+		{filter: "tags/any(t: t.a eq 1)", want: "tags/any(t: t.a eq 1)"},
+		{filter: "address/city eq 'x'", err: "Path separator '/' isn't allowed; use '.'"},
+	}, Options{PathSeparators: PeriodOnly})
+}
+
+func TestParseCST(t *testing.T) {
+	for _, tt := range []struct {
+		filter string
+		nodes  string // Each syntax node's text, indented by its depth
+	}{
+		{"  a eq 1 -- trailing\n", "a eq 1\n a\n 1"},
+		{"/* x */ (a eq 1 /* y */ and\n\tnot (b eq 'é'))  or  5 lt c // z",
+			"(a eq 1 /* y */ and\n\tnot (b eq 'é'))  or  5 lt c\n a eq 1 /* y */ and\n\tnot (b eq 'é')\n  a eq 1\n   a\n   1\n" +
+				"  not (b eq 'é')\n   b eq 'é'\n    b\n    'é'\n 5 lt c\n  5\n  c"},
+		{"items/any(i: i[0] eq null) and n in ( 1 , 2 )", "items/any(i: i[0] eq null) and n in ( 1 , 2 )\n items/any(i: i[0] eq null)\n" +
+			"  items\n  i[0] eq null\n   i[0]\n   null\n n in ( 1 , 2 )\n  n\n  1\n  2"},
+		{"a eq 'x\x00y'", "a eq 'x\x00y'\n a\n 'x\x00y'"},
+	} {
+		cst, err := ParseCST(tt.filter, Options{})
+		if err != nil {
+			t.Errorf("ParseCST(%q): %v", tt.filter, err)
+			continue
+		}
+		if cst.String() != tt.filter {
+			t.Errorf("ParseCST(%q).String() = %q", tt.filter, cst.String())
+		}
+		var nodes []string
+		var walk func(n *SyntaxNode, depth int)
+		walk = func(n *SyntaxNode, depth int) {
+			nodes = append(nodes, strings.Repeat(" ", depth)+n.String())
+			for _, c := range n.Children {
+				walk(c, depth+1)
+			}
+		}
+		walk(cst.Root, 0)
+		if got := strings.Join(nodes, "\n"); got != tt.nodes {
+			t.Errorf("ParseCST(%q) nodes =\n%s\nwant\n%s", tt.filter, got, tt.nodes)
+		}
+	}
+	if _, err := ParseCST("a eq 1\x00 and b eq 2", Options{}); err == nil || !strings.Contains(err.Error(), `Invalid character: '\x00'`) {
+		t.Errorf("ParseCST with a NUL: err = %v, want an invalid character", err)
+	}
+
+	cst, err := ParseCST("child.n eq 42 and /* x */ n gt child/n", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rename func(n *SyntaxNode)
+	rename = func(n *SyntaxNode) {
+		if p, ok := n.Node.(*Property); ok && p.String() == "child.n" {
+			n.Tokens[0].Symbol = "kid"
+			for i := 1; i < len(n.Tokens); i++ {
+				n.Tokens[i].Symbol = ""
+			}
+		}
+		for _, c := range n.Children {
+			rename(c)
+		}
+	}
+	rename(cst.Root)
+	if want := "kid eq 42 and /* x */ n gt kid"; cst.String() != want {
+		t.Errorf("renamed CST = %q, want %q", cst.String(), want)
+	}
+}
+
+func TestParseExists(t *testing.T) {
+	testParse(t, []parseTest{
+		{filter: "exists(a) and not isdefined(b/c)", want: "exists(a) and not isdefined(b.c)"},
+		{filter: "tags/any(t: exists(t/x))", want: "tags/any(t: exists(t/x))"},
+		{filter: "exists(/a~1b)", want: `exists("a/b")`},
+		{filter: "exists(1)", err: "requires a property argument"},
+		{filter: "exists(a add 1)", err: "requires a property argument"},
+		{filter: "exists(a) eq true", want: "exists(a) eq true"},
+	}, Options{})
+}
+
+func TestKindOf(t *testing.T) {
+	type cents int64
+	type ratio float32
+	for _, tt := range []struct {
+		v    any
+		want Kind
+	}{
+		{cents(1999), KindNumber}, {ratio(0.5), KindNumber}, {uint8(7), KindNumber}, {time.May, KindNumber},
+		{time.Hour, KindDuration}, {TimeOfDay(time.Hour), KindTimeOfDay}, {GUID{}, KindGUID}, {[]int{1}, KindArray},
+		{complex(1, 2), KindOther},
+	} {
+		if got := KindOf(tt.v); got != tt.want {
+			t.Errorf("KindOf(%T) = %s, want %s", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	for _, tt := range []struct {
+		s, want string
+	}{
+		{"19.99", "19.99"}, {"-.5", "-0.5"}, {"1.5e3", "1500"}, {"0.001", "0.001"}, {"-0.30", "-0.30"}, {"5", "5"},
+		{"1e-3", "0.001"}, {"abc", "Decimal has improper syntax"}, {"1e9999", "Decimal out of range"},
+	} {
+		d, err := ParseDecimal(tt.s)
+		got := d.String()
+		if err != nil {
+			got = err.Error()
+		}
+		if !strings.HasPrefix(got, tt.want) {
+			t.Errorf("ParseDecimal(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"strings"
+)
+
+// Schema maps property paths (with a period separating each path element, ex: address.city)
+// to the kind of the property's value.
+type Schema map[string]Kind
+
+// Options customize how Parse parses a filter.
+type Options struct {
+	// Schema, if not nil, lets Parse report type mismatches involving the schema's properties
+	// instead of Evaluate reporting them.
+	Schema Schema
+
+	// CaseInsensitive, if true, lets keywords, operators, function names, null, true, false, and type
+	// names appear in any case: Name EQ 'x' AND Active eq TRUE AND At lt Time'2024-05-01T00:00:00Z'.
+	// Property names are still case-sensitive.
+	CaseInsensitive bool
+
+	// PathSeparators determines which characters may separate the names in a property path; the default
+	// allows both. The / before a lambda operator and JSON Pointers are always allowed.
+	PathSeparators PathSeparators
+}
+
+// PathSeparators determines which characters may separate the names in a property path.
+type PathSeparators int
+
+const (
+	// SlashOrPeriod allows both a slash and a period: address/city or address.city
+	SlashOrPeriod PathSeparators = iota
+
+	// SlashOnly allows only a slash, as in OData: address/city
+	SlashOnly
+
+	// PeriodOnly allows only a period: address.city
+	PeriodOnly
+)
+
+// staticKind returns the kind of value n produces if it can be determined without evaluating n; otherwise "".
+func (p *parser) staticKind(n Node) Kind {
+	switch n := n.(type) {
+	case *Literal:
+		return KindOf(n.Value)
+	case *Property:
+		if n.Variable == "" && p.options.Schema != nil {
+			return p.options.Schema[strings.Join(n.Path, ".")]
+		}
+	case *Arithmetic:
+		k, _ := n.Op.resultKind(p.staticKind(n.Left), p.staticKind(n.Right))
+		return k
+	case *Call:
+		return functions[n.Name].result
+	case *Logical, *Not, *Comparison, *In, *Lambda:
+		return KindBoolean
+	}
+	return ""
+}
+
+// checkKinds returns an error if left and right are known to produce values of incompatible kinds.
+func (p *parser) checkKinds(left, right Node) error {
+	lk, rk := p.staticKind(left), p.staticKind(right)
+	if lk == "" || rk == "" || lk == rk || lk == KindNull || rk == KindNull {
+		return nil
+	}
+	if (lk == KindString && rk == KindGUID) || (lk == KindGUID && rk == KindString) { // A string in GUID form
+		return nil
+	}
+	if (lk == KindString && isDecimalLiteral(right)) || (rk == KindString && isDecimalLiteral(left)) { // A string in decimal form
+		return nil
+	}
+	return errorf(left.Pos(), "Type mismatch: %s is a %s but %s is a %s", left, lk, right, rk)
+}
+
+// isDecimalLiteral returns true if n is a decimal literal, which may be compared with a string in decimal form.
+func isDecimalLiteral(n Node) bool {
+	l, ok := n.(*Literal)
+	return ok && isDecimal(l.Value)
+}
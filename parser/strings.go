@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// evaluateContains returns true if the 1st argument (a string) contains the 2nd argument (a string).
+func evaluateContains(c *Call, env Environment, args []any) (any, error) {
+	return stringPredicate(c, args, strings.Contains)
+}
+
+// evaluateStartsWith returns true if the 1st argument (a string) starts with the 2nd argument (a string).
+func evaluateStartsWith(c *Call, env Environment, args []any) (any, error) {
+	return stringPredicate(c, args, strings.HasPrefix)
+}
+
+// evaluateEndsWith returns true if the 1st argument (a string) ends with the 2nd argument (a string).
+func evaluateEndsWith(c *Call, env Environment, args []any) (any, error) {
+	return stringPredicate(c, args, strings.HasSuffix)
+}
+
+// stringPredicate applies predicate to the 2 string arguments.
+func stringPredicate(c *Call, args []any, predicate func(s, substr string) bool) (any, error) {
+	s, err := stringArg(c, args, 0)
+	if err != nil {
+		return false, err
+	}
+	substr, err := stringArg(c, args, 1)
+	if err != nil {
+		return false, err
+	}
+	return predicate(s, substr), nil
+}
+
+// evaluateLength returns the number of characters in the argument (a string).
+func evaluateLength(c *Call, env Environment, args []any) (any, error) {
+	s, err := stringArg(c, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return int64(utf8.RuneCountInString(s)), nil
+}
+
+// evaluateIndexOf returns the zero-based character position of the 2nd argument (a string) within
+// the 1st argument (a string) or -1 if it isn't found.
+func evaluateIndexOf(c *Call, env Environment, args []any) (any, error) {
+	s, err := stringArg(c, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	substr, err := stringArg(c, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	i := strings.Index(s, substr)
+	if i < 0 {
+		return int64(-1), nil
+	}
+	return int64(utf8.RuneCountInString(s[:i])), nil
+}
+
+// evaluateSubstring returns the characters of the 1st argument (a string) starting at the zero-based
+// character position in the 2nd argument (an integer). If present, the 3rd argument (an integer) is
+// the maximum number of characters to return. Positions and lengths outside the string are clamped to it.
+func evaluateSubstring(c *Call, env Environment, args []any) (any, error) {
+	s, err := stringArg(c, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	start, err := integerArg(c, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	start = clamp(start, 0, int64(len(runes)))
+	end := int64(len(runes))
+	if len(args) > 2 {
+		length, err := integerArg(c, args, 2)
+		if err != nil {
+			return nil, err
+		}
+		end = clamp(start+clamp(length, 0, end), start, end)
+	}
+	return string(runes[start:end]), nil
+}
+
+// clamp returns v limited to the range [min, max].
+func clamp(v, min, max int64) int64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// evaluateToLower returns the argument (a string) in lowercase.
+func evaluateToLower(c *Call, env Environment, args []any) (any, error) {
+	return stringFunction(c, args, strings.ToLower)
+}
+
+// evaluateToUpper returns the argument (a string) in uppercase.
+func evaluateToUpper(c *Call, env Environment, args []any) (any, error) {
+	return stringFunction(c, args, strings.ToUpper)
+}
+
+// evaluateTrim returns the argument (a string) without leading and trailing whitespace.
+func evaluateTrim(c *Call, env Environment, args []any) (any, error) {
+	return stringFunction(c, args, strings.TrimSpace)
+}
+
+// stringFunction applies f to the string argument.
+func stringFunction(c *Call, args []any, f func(s string) string) (any, error) {
+	s, err := stringArg(c, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return f(s), nil
+}
+
+// evaluateConcat returns the 1st argument (a string) followed by the 2nd argument (a string).
+func evaluateConcat(c *Call, env Environment, args []any) (any, error) {
+	s1, err := stringArg(c, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	s2, err := stringArg(c, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return s1 + s2, nil
+}